@@ -0,0 +1,46 @@
+package cachecore
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseAge parses an age threshold such as "30d", "2w" or "12h". Days and
+// weeks are accepted in addition to everything time.ParseDuration accepts.
+// An empty string or "0" means no threshold.
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if mult, ok := units[s[len(s)-1]]; ok {
+		n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		// Reject NaN, infinities and anything that would overflow a time.Duration
+		if err != nil || math.IsNaN(n) || n < 0 || n*float64(mult) >= math.MaxInt64 {
+			return 0, fmt.Errorf("invalid age %q (use e.g. 30d, 2w or 12h)", s)
+		}
+		return time.Duration(n * float64(mult)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 30d, 2w or 12h)", s)
+	}
+	return d, nil
+}
+
+// HumanAge formats a duration in the largest whole unit of days, hours or
+// minutes (e.g. "45d", "3h").
+func HumanAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	}
+}
@@ -0,0 +1,61 @@
+package cachecore
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// Boundary keeps a walk on the filesystems its roots are on, like find -xdev,
+// and remembers the mount points it stopped at. A nil Boundary crosses
+// everything, as does any Boundary where device IDs are not available.
+type Boundary struct {
+	devs map[uint64]bool
+
+	mu      sync.Mutex
+	skipped []string
+}
+
+// NewBoundary returns a Boundary for the filesystems holding roots. Roots
+// that cannot be read add nothing.
+func NewBoundary(roots ...string) *Boundary {
+	b := &Boundary{devs: map[uint64]bool{}}
+	for _, root := range roots {
+		if info, err := os.Stat(root); err == nil {
+			if dev, ok := deviceID(info); ok {
+				b.devs[dev] = true
+			}
+		}
+	}
+	return b
+}
+
+// Crosses reports whether the directory d at path is on another filesystem,
+// and records it if so. Call it before descending into d.
+func (b *Boundary) Crosses(path string, d fs.DirEntry) bool {
+	if b == nil || len(b.devs) == 0 {
+		return false
+	}
+	info, err := d.Info()
+	if err != nil {
+		return false
+	}
+	dev, ok := deviceID(info)
+	if !ok || b.devs[dev] {
+		return false
+	}
+	b.mu.Lock()
+	b.skipped = append(b.skipped, path)
+	b.mu.Unlock()
+	return true
+}
+
+// Skipped returns the mount points skipped so far, in the order they were met.
+func (b *Boundary) Skipped() []string {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.skipped...)
+}
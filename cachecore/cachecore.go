@@ -0,0 +1,89 @@
+// Package cachecore holds the scanning and reporting primitives shared by
+// dev-cache, git-cleaner and mac-cache-cleaner: the directory sizing engine,
+// path expansion, the report model and YAML config IO.
+package cachecore
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Human formats a byte count using binary units (e.g. "1.50 MB").
+func Human(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	units := []string{"KB", "MB", "GB", "TB"}
+	v := float64(n)
+	for i, u := range units {
+		v /= 1024
+		if v < 1024 || i == len(units)-1 {
+			return fmt.Sprintf("%.2f %s", v, u)
+		}
+	}
+	return fmt.Sprintf("%.2f TB", v/1024)
+}
+
+// SignedHuman formats a byte delta like Human with an explicit sign (e.g. "+1.50 MB").
+func SignedHuman(n int64) string {
+	if n < 0 {
+		return "-" + Human(-n)
+	}
+	return "+" + Human(n)
+}
+
+// ParseSize parses a byte size such as "500MB", "1.5G" or "4096" using the same
+// binary units as Human. An empty string or "0" means no threshold.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	num := strings.TrimRight(strings.ToUpper(s), "BI")
+	mult := int64(1)
+	if n := len(num); n > 0 {
+		if i := strings.IndexByte("KMGT", num[n-1]); i >= 0 {
+			mult = 1 << (10 * (i + 1))
+			num = num[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	// Reject NaN, infinities and anything that would overflow an int64
+	if err != nil || math.IsNaN(v) || v < 0 || v*float64(mult) >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 500MB, 1.5GB or 4096)", s)
+	}
+	return int64(v * float64(mult)), nil
+}
+
+// Home returns the current user's home directory, or "" if it is unknown.
+func Home() string { h, _ := os.UserHomeDir(); return h }
+
+// Expand replaces a leading "~" (either "~" alone or "~/...") with the user's
+// home directory and then expands environment variables. A "~" anywhere else
+// in the path is left untouched.
+func Expand(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, "~"+string(filepath.Separator)) {
+		if h := Home(); h != "" {
+			p = h + p[1:]
+		}
+	}
+	return os.ExpandEnv(p)
+}
+
+// CheckVersionFlag reports whether -version or --version was passed. It is
+// checked before flag.Parse so that it works alongside any other flags.
+func CheckVersionFlag() bool {
+	for _, arg := range os.Args[1:] {
+		if arg == "-version" || arg == "--version" {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureDir creates the parent directory of p.
+func EnsureDir(p string) error { return os.MkdirAll(filepath.Dir(p), 0o755) }
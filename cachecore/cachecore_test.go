@@ -0,0 +1,768 @@
+package cachecore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHuman(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.00 KB"},
+		{1024*1024 + 512*1024, "1.50 MB"},
+		{1024 * 1024 * 1024, "1.00 GB"},
+		{1024 * 1024 * 1024 * 1024 * 1024, "1024.00 TB"},
+	}
+	for _, tt := range tests {
+		if got := Human(tt.in); got != tt.want {
+			t.Fatalf("Human(%d)=%q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	h, _ := os.UserHomeDir()
+	_ = os.Setenv("CACHECORE_TEST_ENV", "xyz")
+	defer func() { _ = os.Unsetenv("CACHECORE_TEST_ENV") }()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"~", h},
+		{"~/src", h + "/src"},
+		{"$CACHECORE_TEST_ENV/cache", "xyz/cache"},
+		{"/data/~backup/x", "/data/~backup/x"}, // only a leading ~ is expanded
+		{"~other", "~other"},
+	}
+	for _, tt := range tests {
+		if got := Expand(tt.in); got != tt.want {
+			t.Fatalf("Expand(%q)=%q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCheckVersionFlag(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"program", "--config", "x.yaml"}
+	if CheckVersionFlag() {
+		t.Fatal("expected false without version flag")
+	}
+	os.Args = []string{"program", "--config", "x.yaml", "-version"}
+	if !CheckVersionFlag() {
+		t.Fatal("expected true with -version")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.bin"), make([]byte, 2048), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect error: %v", err)
+	}
+	if f.Path != dir || f.Items != 2 || f.SizeBytes != 2053 {
+		t.Fatalf("unexpected finding: %+v", f)
+	}
+	if f.ModMax.IsZero() {
+		t.Fatal("expected ModMax to be set")
+	}
+
+	if _, err := Inspect(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected error for missing path")
+	}
+}
+
+func TestNewEnvelope(t *testing.T) {
+	e := NewEnvelope(true)
+	if !e.DryRun || e.OS == "" || e.Arch == "" || e.When.IsZero() {
+		t.Fatalf("unexpected envelope: %+v", e)
+	}
+}
+
+func TestDefaultConfigPath(t *testing.T) {
+	p := DefaultConfigPath("some-tool")
+	if !strings.HasSuffix(p, filepath.Join("some-tool", "config.yaml")) {
+		t.Fatalf("DefaultConfigPath unexpected: %q", p)
+	}
+}
+
+func TestConfigIO(t *testing.T) {
+	type cfg struct {
+		Version int    `yaml:"version"`
+		Name    string `yaml:"name"`
+	}
+	path := filepath.Join(t.TempDir(), "nested", "config.yaml")
+
+	if err := WriteConfig(path, false, cfg{Version: 1, Name: "a"}); err != nil {
+		t.Fatalf("WriteConfig failed: %v", err)
+	}
+	if err := WriteConfig(path, false, cfg{Version: 1, Name: "b"}); err == nil {
+		t.Fatal("expected error when overwriting without force")
+	}
+	if err := WriteConfig(path, true, cfg{Version: 2, Name: "b"}); err != nil {
+		t.Fatalf("WriteConfig with force failed: %v", err)
+	}
+	matches, _ := filepath.Glob(path + ".*")
+	if len(matches) != 1 {
+		t.Fatalf("expected one backup file, got %v", matches)
+	}
+
+	got, err := LoadConfig[cfg](path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if got.Version != 2 || got.Name != "b" {
+		t.Fatalf("unexpected config: %+v", got)
+	}
+	if _, err := LoadConfig[cfg](filepath.Join(filepath.Dir(path), "missing.yaml")); err == nil {
+		t.Fatal("expected error for missing config")
+	}
+}
+
+func TestSizerInspectAll(t *testing.T) {
+	root := t.TempDir()
+	var paths []string
+	for i, size := range []int{10, 300, 20, 4000, 1} {
+		dir := filepath.Join(root, fmt.Sprintf("d%d", i))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "f"), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, dir)
+	}
+	paths = append(paths, filepath.Join(root, "missing"))
+
+	for _, jobs := range []int{0, 1, 3, 100} {
+		findings, errs := Sizer{Jobs: jobs}.InspectAll(paths)
+		if len(findings) != len(paths) || len(errs) != len(paths) {
+			t.Fatalf("jobs=%d: expected %d results, got %d/%d", jobs, len(paths), len(findings), len(errs))
+		}
+		for i, want := range []int64{10, 300, 20, 4000, 1} {
+			if findings[i].Path != paths[i] || findings[i].SizeBytes != want || errs[i] != nil {
+				t.Fatalf("jobs=%d: result %d out of order or wrong: %+v (err %v)", jobs, i, findings[i], errs[i])
+			}
+		}
+		if errs[len(paths)-1] == nil {
+			t.Fatalf("jobs=%d: expected error for missing path", jobs)
+		}
+	}
+
+	if f, errs := (Sizer{}).InspectAll(nil); len(f) != 0 || len(errs) != 0 {
+		t.Fatal("expected empty results for no paths")
+	}
+
+	var calls, last int
+	s := Sizer{Jobs: 3, Progress: func(done, total int) {
+		calls++
+		last = done
+		if total != len(paths) {
+			t.Errorf("Progress total = %d, want %d", total, len(paths))
+		}
+	}}
+	s.InspectAll(paths)
+	if calls != len(paths) || last != len(paths) {
+		t.Fatalf("expected %d progress calls ending at %d, got %d ending at %d", len(paths), len(paths), calls, last)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"abc", 0, true},
+		{"-3d", 0, true},
+		{"xd", 0, true},
+		{"NaNd", 0, true},
+		{"Infd", 0, true},
+		{"+Infw", 0, true},
+		{"1e300d", 0, true},
+		{"1e6w", 0, true},
+		{"106751d", 106751 * 24 * time.Hour, false},
+		{"106752d", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Fatalf("ParseAge(%q) = %v, %v; want %v, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestHumanAge(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{45 * 24 * time.Hour, "45d"},
+		{3*time.Hour + 10*time.Minute, "3h"},
+		{5 * time.Minute, "5m"},
+	}
+	for _, tt := range tests {
+		if got := HumanAge(tt.in); got != tt.want {
+			t.Fatalf("HumanAge(%v)=%q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultStateDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+	if got := DefaultStateDir("dev-cache"); got != filepath.Join("/tmp/state", "dev-cache") {
+		t.Fatalf("DefaultStateDir() = %q", got)
+	}
+}
+
+func TestQuarantineHoldRestorePurge(t *testing.T) {
+	q := Quarantine{Dir: filepath.Join(t.TempDir(), "quarantine")}
+	project := t.TempDir()
+	cache := filepath.Join(project, "node_modules")
+	if err := os.MkdirAll(filepath.Join(cache, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := q.Begin(time.Now())
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	if err := run.Hold(cache, 42); err != nil {
+		t.Fatalf("Hold error: %v", err)
+	}
+	if _, err := os.Stat(cache); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be moved away", cache)
+	}
+
+	runs, err := q.Runs()
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("Runs() = %d runs, %v", len(runs), err)
+	}
+	if runs[0].ID != run.ID || runs[0].TotalBytes() != 42 || runs[0].Entries[0].Original != cache {
+		t.Fatalf("unexpected manifest: %+v", runs[0])
+	}
+
+	if errs := runs[0].Restore(); len(errs) != 0 {
+		t.Fatalf("Restore errors: %v", errs)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "pkg")); err != nil {
+		t.Fatalf("expected %s to be restored: %v", cache, err)
+	}
+	if runs, _ := q.Runs(); len(runs) != 0 {
+		t.Fatalf("expected restored run to be removed, got %d", len(runs))
+	}
+
+	// Hold again and purge for good
+	run, err = q.Begin(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Hold(cache, 42); err != nil {
+		t.Fatal(err)
+	}
+	held := run.Entries[0].Held
+	if err := run.Purge(); err != nil {
+		t.Fatalf("Purge error: %v", err)
+	}
+	if _, err := os.Stat(held); !os.IsNotExist(err) {
+		t.Fatal("expected held directory to be deleted")
+	}
+	if runs, _ := q.Runs(); len(runs) != 0 {
+		t.Fatalf("expected purged run to be removed, got %d", len(runs))
+	}
+}
+
+func TestQuarantineRestoreKeepsExisting(t *testing.T) {
+	q := Quarantine{Dir: t.TempDir()}
+	cache := filepath.Join(t.TempDir(), ".venv")
+	if err := os.Mkdir(cache, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	run, err := q.Begin(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Hold(cache, 1); err != nil {
+		t.Fatal(err)
+	}
+	// The project recreated its cache after the cleanup
+	if err := os.Mkdir(cache, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if errs := run.Restore(); len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", errs)
+	}
+	if runs, _ := q.Runs(); len(runs) != 1 || len(runs[0].Entries) != 1 {
+		t.Fatal("expected the entry to stay in quarantine")
+	}
+}
+
+func TestQuarantineCloseRemovesEmptyRun(t *testing.T) {
+	q := Quarantine{Dir: t.TempDir()}
+	run, err := q.Begin(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Hold(filepath.Join(t.TempDir(), "missing"), 1); err == nil {
+		t.Fatal("expected Hold of a missing path to fail")
+	}
+	if err := run.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if runs, _ := q.Runs(); len(runs) != 0 {
+		t.Fatalf("expected empty run to be removed, got %d", len(runs))
+	}
+}
+
+func TestQuarantineCrossDeviceHolder(t *testing.T) {
+	orig := renameToArea
+	renameToArea = func(string, string) error { return &os.LinkError{Op: "rename", Err: syscall.EXDEV} }
+	defer func() { renameToArea = orig }()
+
+	repo := initRepo(t)
+	cache := filepath.Join(repo, "node_modules")
+	if err := os.MkdirAll(filepath.Join(cache, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("node_modules/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	q := Quarantine{Dir: t.TempDir()}
+	run, err := q.Begin(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Hold(cache, 1); err != nil {
+		t.Fatalf("Hold error: %v", err)
+	}
+	holder := filepath.Join(repo, QuarantineDirPrefix+run.ID)
+	if run.Entries[0].Held != filepath.Join(holder, "node_modules") {
+		t.Fatalf("expected entry held beside the project, got %s", run.Entries[0].Held)
+	}
+	// The holder must not count as untracked work
+	g, err := ReadGitActivity(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Untracked != 1 { // Only the .gitignore written above
+		t.Fatalf("expected holder to be ignored by git, got %+v", g)
+	}
+
+	if errs := run.Restore(); len(errs) != 0 {
+		t.Fatalf("Restore errors: %v", errs)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "pkg")); err != nil {
+		t.Fatalf("expected cache to be restored: %v", err)
+	}
+	if _, err := os.Stat(holder); !os.IsNotExist(err) {
+		t.Fatal("expected holder to be removed after restore")
+	}
+}
+
+func TestSignedHuman(t *testing.T) {
+	if got := SignedHuman(2048); got != "+2.00 KB" {
+		t.Errorf("SignedHuman(2048) = %q", got)
+	}
+	if got := SignedHuman(-10); got != "-10 B" {
+		t.Errorf("SignedHuman(-10) = %q", got)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"4096", 4096, false},
+		{"512B", 512, false},
+		{"1KB", 1024, false},
+		{"1.5M", 1536 * 1024, false},
+		{"2GiB", 2 << 30, false},
+		{"10 mb", 10 << 20, false},
+		{"1TB", 1 << 40, false},
+		{"MB", 0, true},
+		{"-1MB", 0, true},
+		{"NaNMB", 0, true},
+		{"InfGB", 0, true},
+		{"1e300TB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Fatalf("ParseSize(%q) = %d, %v; want %d, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"vendor", "/src/app/vendor", true},
+		{"*.git", "/srv/mirrors/linux.git", true},
+		{"vendor", "/src/vendored", false},
+		{"**/vendor/**", "/src/app/vendor", true},
+		{"**/vendor/**", "/src/app/vendor/lib/.git", true},
+		{"third_party/*", "/src/app/third_party/zlib", true},
+		{"third_party/*", "/src/app/third_party/zlib/sub", false},
+		{"/mnt/*", "/mnt/nas", true},
+		{"/mnt/*", "/data/mnt/nas", false},
+		{"~/src/mirrors/*", "/home/me/src/mirrors/linux", true},
+		{"~/src/mirrors/", "/home/me/src/mirrors", true},
+		{"", "/src", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+	if !MatchAny([]string{"node_modules", "/mnt/*"}, "/mnt/usb") || MatchAny(nil, "/mnt/usb") {
+		t.Error("MatchAny did not match any of the patterns")
+	}
+}
+
+func TestExcluder(t *testing.T) {
+	root := t.TempDir()
+	global := filepath.Join(root, "global-ignore")
+	if err := os.WriteFile(global, []byte("# global\n/mnt/*\n*.tmp/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	proj := filepath.Join(root, "src", "proj")
+	if err := os.MkdirAll(proj, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "src", IgnoreFileName), []byte("build/\n/proj/data\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(proj, IgnoreFileName), []byte("!build\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewExcluder(global)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Add("--exclude", "node_modules", "!keep_modules")
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"/mnt/nas", true, true},
+		{filepath.Join(root, "cache.tmp"), true, true},
+		{filepath.Join(root, "cache.tmp"), false, false}, // Directory-only rule
+		{filepath.Join(root, "src", "app", "node_modules"), true, true},
+		{filepath.Join(root, "src", "app", "build"), true, true},
+		{filepath.Join(root, "build"), true, false}, // Above the ignore file
+		{filepath.Join(proj, "build"), true, false}, // Re-included by a deeper ignore file
+		{filepath.Join(proj, "data"), true, true},   // Anchored to the ignore file's directory
+		{filepath.Join(root, "src", "x", "proj", "data"), true, false},
+		{filepath.Join(root, "src", "keep_modules"), true, false},
+	}
+	for _, tt := range tests {
+		if got := e.Skip(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Skip(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	excluded := e.Excluded()
+	if len(excluded) != 5 || excluded[0] != (ExcludedPath{Path: "/mnt/nas", Pattern: "/mnt/*", Source: global}) {
+		t.Fatalf("unexpected excluded paths: %+v", excluded)
+	}
+	want := "5 paths excluded: 1 by /mnt/* (" + global + "), 1 by *.tmp/ (" + global + "), 1 by node_modules (--exclude), "
+	if got := ExcludedSummary(excluded); !strings.HasPrefix(got, want) {
+		t.Fatalf("ExcludedSummary() = %q, want prefix %q", got, want)
+	}
+	if ExcludedSummary(nil) != "" {
+		t.Fatal("expected an empty summary when nothing was excluded")
+	}
+
+	var none *Excluder
+	if none.Skip("/mnt/nas", true) || len(none.Excluded()) != 0 {
+		t.Fatal("a nil Excluder should exclude nothing")
+	}
+	if e, err := NewExcluder(filepath.Join(root, "missing")); err != nil || e.Skip("/mnt/nas", true) {
+		t.Fatalf("a missing global ignore file should add no rules, got %v", err)
+	}
+
+	var p Patterns
+	_ = p.Set("a")
+	_ = p.Set("b")
+	if p.String() != "a,b" {
+		t.Fatalf("Patterns = %q", p.String())
+	}
+}
+
+func TestSizerExclude(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"keep/a", "skip/b"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte("12345"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, _ := NewExcluder("")
+	e.Add("--exclude", "skip")
+	for _, idx := range []*Index{nil, LoadIndex(filepath.Join(t.TempDir(), "index.json"))} {
+		findings, errs := Sizer{Jobs: 1, Exclude: e, Index: idx}.InspectAll([]string{root})
+		if errs[0] != nil || findings[0].SizeBytes != 5 || findings[0].Items != 1 || findings[0].Excluded != 1 {
+			t.Fatalf("index %v: expected the skip directory to be left out, got %+v (%v)", idx != nil, findings[0], errs[0])
+		}
+	}
+}
+
+func TestHistoryAppendLoad(t *testing.T) {
+	h := History{Path: filepath.Join(t.TempDir(), "state", "history.jsonl")}
+	if recs, err := h.Load(); err != nil || len(recs) != 0 {
+		t.Fatalf("expected empty history, got %d records, %v", len(recs), err)
+	}
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	// Appended out of order; Load returns them oldest first
+	for _, rec := range []HistoryRecord{
+		{When: now.Add(time.Hour), Total: 2},
+		{When: now, Total: 1, DryRun: true},
+	} {
+		if err := h.Append(rec); err != nil {
+			t.Fatalf("Append error: %v", err)
+		}
+	}
+	// A partially written line is skipped
+	f, err := os.OpenFile(h.Path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"when": "2025-`); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	recs, err := h.Load()
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(recs) != 2 || recs[0].Total != 1 || !recs[0].DryRun || recs[1].Total != 2 {
+		t.Fatalf("unexpected records: %+v", recs)
+	}
+}
+
+func TestHistoryGrowthAndRegrowth(t *testing.T) {
+	day := 24 * time.Hour
+	t0 := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	recs := []HistoryRecord{
+		{
+			When:    t0,
+			Groups:  map[string]map[string]int64{"language": {"node": 1000, "go": 500}},
+			Paths:   map[string]int64{"/a/node_modules": 1000, "/b/.cache": 500},
+			Cleaned: map[string]int64{"/a/node_modules": 0, "/b/.cache": 100},
+		},
+		{
+			When:   t0.Add(2 * day),
+			Groups: map[string]map[string]int64{"language": {"node": 400, "go": 300}},
+			Paths:  map[string]int64{"/a/node_modules": 400, "/b/.cache": 300},
+		},
+		{
+			When:   t0.Add(4 * day),
+			Groups: map[string]map[string]int64{"language": {"node": 1800}},
+			Paths:  map[string]int64{"/a/node_modules": 1800},
+		},
+	}
+
+	growth := Growth(recs, "language")
+	if len(growth) != 2 || growth[0].Key != "node" || growth[1].Key != "go" {
+		t.Fatalf("unexpected growth order: %+v", growth)
+	}
+	if g := growth[0]; g.First != 1000 || g.Last != 1800 || g.PerDay != 200 {
+		t.Fatalf("unexpected node growth: %+v", g)
+	}
+	// go was last seen on day 2
+	if g := growth[1]; g.First != 500 || g.Last != 300 || g.PerDay != -100 {
+		t.Fatalf("unexpected go growth: %+v", g)
+	}
+
+	regrowth := Regrowth(recs)
+	if len(regrowth) != 2 {
+		t.Fatalf("expected 2 regrowing caches, got %+v", regrowth)
+	}
+	if r := regrowth[0]; r.Path != "/a/node_modules" || r.Size != 1800 || r.PerDay != 450 || !r.CleanedAt.Equal(t0) {
+		t.Fatalf("unexpected fastest regrowth: %+v", r)
+	}
+	if r := regrowth[1]; r.Path != "/b/.cache" || r.PerDay != 100 {
+		t.Fatalf("unexpected second regrowth: %+v", r)
+	}
+
+	sum := Summarize(nil, []string{"language"})
+	if sum.Runs == nil || sum.Regrowth == nil || sum.Growth["language"] == nil {
+		t.Fatalf("expected empty but non-nil summary, got %+v", sum)
+	}
+}
+
+// ageDirs backdates every directory under root so the index will cache it.
+func ageDirs(t *testing.T, root string) {
+	t.Helper()
+	old := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			err = os.Chtimes(p, old, old)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexReusesUnchangedDirectories(t *testing.T) {
+	root := t.TempDir()
+	cache := filepath.Join(root, "cache")
+	for _, f := range []struct {
+		path string
+		size int
+	}{{"a", 10}, {"sub/b", 200}, {"sub/deep/c", 3000}} {
+		p := filepath.Join(cache, f.path)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, make([]byte, f.size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ageDirs(t, root)
+
+	want, err := Inspect(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxPath := filepath.Join(t.TempDir(), "index.json")
+	x := LoadIndex(idxPath)
+	got, errs := Sizer{Index: x}.InspectAll([]string{cache})
+	if errs[0] != nil || got[0].SizeBytes != want.SizeBytes || got[0].Items != want.Items || got[0].DiskBytes != want.DiskBytes || !got[0].ModMax.Equal(want.ModMax) {
+		t.Fatalf("indexed walk = %+v, want %+v", got[0], want)
+	}
+	if err := x.Save(); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	// Rewrite a file in place and restore its directory's mtime: the cached total is reused
+	deep := filepath.Join(cache, "sub", "deep")
+	if err := os.WriteFile(filepath.Join(deep, "c"), make([]byte, 5), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ageDirs(t, root)
+	got, _ = Sizer{Index: LoadIndex(idxPath)}.InspectAll([]string{cache})
+	if got[0].SizeBytes != want.SizeBytes {
+		t.Fatalf("expected unchanged directory to be reused, got %d want %d", got[0].SizeBytes, want.SizeBytes)
+	}
+
+	// Adding a file changes the directory's mtime, so it is read again
+	if err := os.WriteFile(filepath.Join(deep, "d"), make([]byte, 7), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(deep, later, later); err != nil {
+		t.Fatal(err)
+	}
+	x = LoadIndex(idxPath)
+	got, _ = Sizer{Index: x}.InspectAll([]string{cache})
+	if want := int64(10 + 200 + 5 + 7); got[0].SizeBytes != want || got[0].Items != 4 {
+		t.Fatalf("expected changed directory to be re-read, got %+v", got[0])
+	}
+
+	// Entries of deleted directories below a walked root are pruned on save
+	if err := os.RemoveAll(filepath.Join(cache, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	x = LoadIndex(idxPath)
+	Sizer{Index: x}.InspectAll([]string{cache})
+	if err := x.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(LoadIndex(idxPath).dirs); n != 1 {
+		t.Fatalf("expected only %s left in the index, got %d entries", cache, n)
+	}
+}
+
+func TestIndexPrunesDeletedRoots(t *testing.T) {
+	root := t.TempDir()
+	gone, dropped, kept := filepath.Join(root, "gone"), filepath.Join(root, "dropped"), filepath.Join(root, "kept")
+	for _, dir := range []string{gone, dropped, kept} {
+		if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ageDirs(t, root)
+	idxPath := filepath.Join(t.TempDir(), "index.json")
+	x := LoadIndex(idxPath)
+	Sizer{Index: x}.InspectAll([]string{gone, dropped, kept})
+	if err := x.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// gone is still a configured root, dropped no longer is; both have been deleted
+	for _, dir := range []string{gone, dropped} {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	x = LoadIndex(idxPath)
+	Sizer{Index: x}.InspectAll([]string{gone})
+	if err := x.Save(); err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for dir := range LoadIndex(idxPath).dirs {
+		left = append(left, dir)
+	}
+	sort.Strings(left)
+	if want := []string{kept, filepath.Join(kept, "sub")}; strings.Join(left, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected only %s in the index, got %v", kept, left)
+	}
+}
+
+func TestLoadIndexMissingOrCorrupt(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "index.json")
+	if x := LoadIndex(p); x == nil || len(x.dirs) != 0 {
+		t.Fatal("expected empty index for a missing file")
+	}
+	if err := os.WriteFile(p, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if x := LoadIndex(p); x == nil || len(x.dirs) != 0 {
+		t.Fatal("expected empty index for a corrupt file")
+	}
+}
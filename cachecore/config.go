@@ -0,0 +1,69 @@
+package cachecore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DefaultConfigPath returns ~/.config/<app>/config.yaml, or ./config.yaml when
+// the home directory is unknown.
+func DefaultConfigPath(app string) string {
+	h, _ := os.UserHomeDir()
+	if h == "" {
+		return "./config.yaml"
+	}
+	return filepath.Join(h, ".config", app, "config.yaml")
+}
+
+// LoadConfig reads and decodes the YAML config at path.
+func LoadConfig[T any](path string) (*T, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg T
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// WriteConfig encodes cfg as YAML to path. An existing file is only replaced
+// when force is set, in which case it is first renamed to a timestamped backup.
+func WriteConfig(path string, force bool, cfg any) error {
+	if _, err := os.Stat(path); err == nil {
+		if !force {
+			return fmt.Errorf("config file already exists at %s. Use --force to overwrite", path)
+		}
+		backupPath := fmt.Sprintf("%s.%s", path, time.Now().Format("20060102-150405"))
+		if err := os.Rename(path, backupPath); err != nil {
+			return fmt.Errorf("failed to backup existing config: %w", err)
+		}
+		fmt.Printf("Existing config backed up to: %s\n", backupPath)
+	}
+	if err := EnsureDir(path); err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// DefaultStateDir returns $XDG_STATE_HOME/<app>, falling back to ~/.local/state/<app>,
+// or ./.<app>-state when the home directory is unknown.
+func DefaultStateDir(app string) string {
+	if x := os.Getenv("XDG_STATE_HOME"); x != "" {
+		return filepath.Join(x, app)
+	}
+	h, _ := os.UserHomeDir()
+	if h == "" {
+		return "." + app + "-state"
+	}
+	return filepath.Join(h, ".local", "state", app)
+}
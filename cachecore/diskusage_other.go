@@ -0,0 +1,16 @@
+//go:build !unix
+
+package cachecore
+
+import "io/fs"
+
+// diskUsage falls back to the apparent size where allocated blocks and inode
+// numbers are not available.
+func diskUsage(info fs.FileInfo) (bytes int64, id fileID, linked bool) {
+	return info.Size(), fileID{}, false
+}
+
+// deviceID is not available, so walks cross every filesystem.
+func deviceID(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package cachecore
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// diskUsage returns the bytes actually allocated for a file (st_blocks is
+// always in 512-byte units) and its (device, inode) identity. linked is true
+// when the file has more than one hard link.
+func diskUsage(info fs.FileInfo) (bytes int64, id fileID, linked bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size(), fileID{}, false
+	}
+	return int64(st.Blocks) * 512, fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, st.Nlink > 1
+}
+
+// deviceID returns the device holding the file described by info.
+func deviceID(info fs.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
@@ -0,0 +1,111 @@
+//go:build unix
+
+package cachecore
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInspectSparseFile(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "sparse.raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(64 << 20); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	got, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect error: %v", err)
+	}
+	if got.ApparentBytes != 64<<20 || got.SizeBytes != got.ApparentBytes {
+		t.Fatalf("expected 64 MiB apparent size, got %+v", got)
+	}
+	if got.DiskBytes >= got.ApparentBytes {
+		t.Fatalf("expected sparse file to use less on disk, got %d", got.DiskBytes)
+	}
+}
+
+func TestInspectAllDedupesHardLinks(t *testing.T) {
+	root := t.TempDir()
+	store := filepath.Join(root, "store")
+	a := filepath.Join(root, "a")
+	b := filepath.Join(root, "b")
+	for _, d := range []string{store, a, b} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blob := filepath.Join(store, "blob")
+	if err := os.WriteFile(blob, make([]byte, 64*1024), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []string{filepath.Join(a, "blob"), filepath.Join(a, "blob2"), filepath.Join(b, "blob")} {
+		if err := os.Link(blob, link); err != nil {
+			t.Skip("hard links not supported:", err)
+		}
+	}
+
+	single, err := Inspect(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single.Items != 2 || single.ApparentBytes != 2*64*1024 {
+		t.Fatalf("expected both links in apparent size, got %+v", single)
+	}
+	if single.DiskBytes >= single.ApparentBytes {
+		t.Fatalf("expected the two links in a to be counted once on disk, got %+v", single)
+	}
+
+	findings, _ := Sizer{Jobs: 2, OnDisk: true}.InspectAll([]string{a, b})
+	if findings[0].DiskBytes != single.DiskBytes || findings[0].SizeBytes != findings[0].DiskBytes {
+		t.Fatalf("expected first path to own the shared blob, got %+v", findings[0])
+	}
+	if findings[1].DiskBytes != 0 || findings[1].ApparentBytes != 64*1024 {
+		t.Fatalf("expected shared blob not to be counted again, got %+v", findings[1])
+	}
+}
+
+func TestBoundary(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	b := NewBoundary(dir)
+	info, err := os.Lstat(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Crosses(sub, fs.FileInfoToDirEntry(info)) || len(b.Skipped()) != 0 {
+		t.Fatal("a subdirectory on the same filesystem should not cross the boundary")
+	}
+
+	// /proc is its own filesystem on Linux; elsewhere there may be nothing to cross into
+	root, err1 := os.Stat("/")
+	proc, err2 := os.Lstat("/proc")
+	if err1 != nil || err2 != nil || !proc.IsDir() {
+		t.Skip("no /proc to test a mount point with")
+	}
+	rootDev, _ := deviceID(root)
+	if procDev, _ := deviceID(proc); procDev == rootDev {
+		t.Skip("/proc is not a separate filesystem here")
+	}
+	b = NewBoundary("/")
+	if !b.Crosses("/proc", fs.FileInfoToDirEntry(proc)) || len(b.Skipped()) != 1 || b.Skipped()[0] != "/proc" {
+		t.Fatalf("expected /proc to be skipped, got %v", b.Skipped())
+	}
+	if NewBoundary("/", "/proc").Crosses("/proc", fs.FileInfoToDirEntry(proc)) {
+		t.Fatal("a mount that is itself a root should not be skipped")
+	}
+	var none *Boundary
+	if none.Crosses("/proc", fs.FileInfoToDirEntry(proc)) || none.Skipped() != nil {
+		t.Fatal("a nil Boundary should cross everything")
+	}
+}
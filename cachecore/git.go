@@ -0,0 +1,200 @@
+package cachecore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotGitRepo is returned when a directory is not inside a git work tree.
+var ErrNotGitRepo = errors.New("not a git repository")
+
+// GitActivity describes how recently and how actively a repository is being worked on.
+type GitActivity struct {
+	Root       string    `json:"root"`                  // Top level of the work tree
+	LastCommit time.Time `json:"last_commit,omitempty"` // Zero when the repository has no commits
+	Changed    int       `json:"changed"`               // Tracked files with uncommitted changes
+	Untracked  int       `json:"untracked"`             // Untracked files that are not ignored
+	Stashes    int       `json:"stashes"`
+}
+
+// Clean reports whether the work tree has no uncommitted changes, untracked files or stashes.
+func (g GitActivity) Clean() bool {
+	return g.Changed == 0 && g.Untracked == 0 && g.Stashes == 0
+}
+
+// git runs a git subcommand in dir and returns its trimmed stdout.
+func git(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// GitTopLevel returns the top level of the git work tree containing dir.
+func GitTopLevel(dir string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git not found: %w", err)
+	}
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil || root == "" {
+		return "", ErrNotGitRepo
+	}
+	return root, nil
+}
+
+// ReadGitActivity inspects the git work tree containing dir using the git CLI.
+func ReadGitActivity(dir string) (GitActivity, error) {
+	var g GitActivity
+	root, err := GitTopLevel(dir)
+	if err != nil {
+		return g, err
+	}
+	g.Root = root
+
+	// A repository without commits makes git log fail; LastCommit stays zero
+	if ts, err := git(root, "log", "-1", "--format=%ct"); err == nil && ts != "" {
+		if secs, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			g.LastCommit = time.Unix(secs, 0)
+		}
+	}
+
+	status, err := git(root, "status", "--porcelain")
+	if err != nil {
+		return g, fmt.Errorf("git status: %w", err)
+	}
+	for _, line := range strings.Split(status, "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "??"):
+			g.Untracked++
+		default:
+			g.Changed++
+		}
+	}
+
+	stashes, err := git(root, "stash", "list")
+	if err != nil {
+		return g, fmt.Errorf("git stash list: %w", err)
+	}
+	if stashes != "" {
+		g.Stashes = len(strings.Split(stashes, "\n"))
+	}
+	return g, nil
+}
+
+// lsFilesBatch is the most pathspecs passed to a single git ls-files call.
+const lsFilesBatch = 1000
+
+// How git treats a path, as reported by GitPathStatuses.
+const (
+	PathIgnored   = "ignored"   // Matched by .gitignore, .git/info/exclude or core.excludesFile
+	PathTracked   = "tracked"   // Contains files committed to the repository
+	PathUntracked = "untracked" // Neither tracked nor ignored
+)
+
+// GitPathStatus reports whether a single path is ignored, tracked or untracked in the
+// git work tree containing it. Use GitPathStatuses to classify many paths at once.
+func GitPathStatus(path string) (string, error) {
+	top, err := GitTopLevel(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	statuses, err := GitPathStatuses(top, []string{path})
+	if err != nil {
+		return "", err
+	}
+	status, ok := statuses[path]
+	if !ok {
+		return "", ErrNotGitRepo
+	}
+	return status, nil
+}
+
+// GitPathStatuses classifies paths inside the work tree at top as ignored, tracked or
+// untracked using a single git check-ignore call and one git ls-files call per
+// lsFilesBatch paths. Nested .gitignore files and global excludes are applied by git itself.
+// A directory holding any tracked file counts as tracked even if an ignore rule matches
+// it. Paths outside top are left out of the result.
+func GitPathStatuses(top string, paths []string) (map[string]string, error) {
+	statuses := make(map[string]string, len(paths))
+	rels := make(map[string]string, len(paths)) // Path relative to top -> path as given
+	var spec bytes.Buffer
+	for _, p := range paths {
+		rel, ok := relToTop(top, p)
+		if !ok {
+			continue
+		}
+		rels[rel] = p
+		statuses[p] = PathUntracked
+		spec.WriteString(rel)
+		spec.WriteByte(0)
+	}
+	if len(rels) == 0 {
+		return statuses, nil
+	}
+
+	// Tracked: any file listed under the path makes the whole directory tracked.
+	// ls-files only takes pathspecs as arguments, so very long lists are split into
+	// batches to stay under the argument size limit.
+	var relList []string
+	for rel := range rels {
+		relList = append(relList, rel)
+	}
+	for start := 0; start < len(relList); start += lsFilesBatch {
+		end := start + lsFilesBatch
+		if end > len(relList) {
+			end = len(relList)
+		}
+		args := append([]string{"-C", top, "--literal-pathspecs", "ls-files", "-z", "--"}, relList[start:end]...)
+		out, err := exec.Command("git", args...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("git ls-files: %w", err)
+		}
+		for _, file := range strings.Split(string(out), "\x00") {
+			for f := filepath.FromSlash(file); f != "" && f != "."; f = filepath.Dir(f) {
+				if p, ok := rels[f]; ok {
+					statuses[p] = PathTracked
+					break
+				}
+			}
+		}
+	}
+
+	// check-ignore exits 0 when something is ignored, 1 when nothing is, and 128 on failure
+	cmd := exec.Command("git", "-C", top, "check-ignore", "-z", "--stdin")
+	cmd.Stdin = bytes.NewReader(spec.Bytes())
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return nil, fmt.Errorf("git check-ignore: %w", err)
+	}
+	for _, rel := range strings.Split(string(out), "\x00") {
+		if p, ok := rels[filepath.FromSlash(rel)]; ok && statuses[p] != PathTracked {
+			statuses[p] = PathIgnored
+		}
+	}
+	return statuses, nil
+}
+
+// relToTop returns path relative to the work tree top, resolving symlinks in path
+// when needed since git reports the top level with symlinks resolved.
+func relToTop(top, path string) (string, bool) {
+	rel, err := filepath.Rel(top, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return "", false
+		}
+		if rel, err = filepath.Rel(top, real); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+	}
+	if rel == "." {
+		return "", false
+	}
+	return rel, true
+}
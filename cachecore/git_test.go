@@ -0,0 +1,135 @@
+package cachecore
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// initRepo creates a git repository in a temp dir with one commit.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "README")
+	run("commit", "-q", "-m", "init")
+	return dir
+}
+
+func TestReadGitActivity(t *testing.T) {
+	dir := initRepo(t)
+	sub := filepath.Join(dir, "sub")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := ReadGitActivity(sub)
+	if err != nil {
+		t.Fatalf("ReadGitActivity error: %v", err)
+	}
+	if g.LastCommit.IsZero() || !g.Clean() {
+		t.Fatalf("expected clean repo with a commit, got %+v", g)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g, err = ReadGitActivity(dir)
+	if err != nil {
+		t.Fatalf("ReadGitActivity error: %v", err)
+	}
+	if g.Changed != 1 || g.Untracked != 1 || g.Clean() {
+		t.Fatalf("expected one changed and one untracked file, got %+v", g)
+	}
+}
+
+func TestReadGitActivityNotRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	_, err := ReadGitActivity(t.TempDir())
+	if !errors.Is(err, ErrNotGitRepo) {
+		t.Fatalf("expected ErrNotGitRepo, got %v", err)
+	}
+}
+
+func TestGitPathStatus(t *testing.T) {
+	dir := initRepo(t)
+	for _, d := range []string{"node_modules", "vendor", "build", "sub/node_modules"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("node_modules/\nvendor/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// vendor is ignored but has a force-added file, so it must count as tracked
+	if err := os.WriteFile(filepath.Join(dir, "vendor", "mod.go"), []byte("package vendor"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", dir, "add", "-f", "vendor/mod.go").CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v\n%s", err, out)
+	}
+
+	tests := map[string]string{
+		"node_modules":     PathIgnored,
+		"sub/node_modules": PathIgnored,
+		"vendor":           PathTracked,
+		"build":            PathUntracked,
+	}
+	for rel, want := range tests {
+		got, err := GitPathStatus(filepath.Join(dir, rel))
+		if err != nil {
+			t.Fatalf("GitPathStatus(%s) error: %v", rel, err)
+		}
+		if got != want {
+			t.Errorf("GitPathStatus(%s) = %q, want %q", rel, got, want)
+		}
+	}
+
+	// Classify every path with one batch
+	top, err := GitTopLevel(dir)
+	if err != nil {
+		t.Fatalf("GitTopLevel error: %v", err)
+	}
+	var paths []string
+	for rel := range tests {
+		paths = append(paths, filepath.Join(dir, rel))
+	}
+	outside := filepath.Join(t.TempDir(), "node_modules")
+	statuses, err := GitPathStatuses(top, append(paths, outside))
+	if err != nil {
+		t.Fatalf("GitPathStatuses error: %v", err)
+	}
+	for rel, want := range tests {
+		if got := statuses[filepath.Join(dir, rel)]; got != want {
+			t.Errorf("GitPathStatuses[%s] = %q, want %q", rel, got, want)
+		}
+	}
+	if _, ok := statuses[outside]; ok {
+		t.Error("expected path outside the work tree to be left out")
+	}
+
+	if _, err := GitPathStatus(filepath.Join(t.TempDir(), "node_modules")); !errors.Is(err, ErrNotGitRepo) {
+		t.Errorf("expected ErrNotGitRepo outside a repository, got %v", err)
+	}
+}
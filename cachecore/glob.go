@@ -0,0 +1,58 @@
+package cachecore
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MatchPath reports whether p matches the glob pattern. A pattern without a
+// slash matches the base name anywhere (like .gitignore). Other patterns match
+// the whole path; a "**" segment matches any number of directories, and a
+// pattern that does not start with "/" or "~" may match at any depth.
+func MatchPath(pattern, p string) bool {
+	pattern = strings.TrimSuffix(filepath.ToSlash(Expand(pattern)), "/")
+	p = filepath.ToSlash(p)
+	if pattern == "" {
+		return false
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(p))
+		return ok
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(p, "/"))
+}
+
+// MatchAny reports whether p matches any of the patterns.
+func MatchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if MatchPath(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
@@ -0,0 +1,188 @@
+package cachecore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// History is an append-only JSON lines file with one record per run.
+type History struct {
+	Path string
+}
+
+// HistoryRecord summarises one run of a cleaner.
+type HistoryRecord struct {
+	When      time.Time                   `json:"when"`
+	DryRun    bool                        `json:"dry_run"`
+	Total     int64                       `json:"total_bytes"`
+	Reclaimed int64                       `json:"reclaimed_bytes"`
+	Groups    map[string]map[string]int64 `json:"groups,omitempty"`  // Dimension (e.g. "language") -> key -> bytes
+	Paths     map[string]int64            `json:"paths,omitempty"`   // Cache directory -> bytes before cleanup
+	Cleaned   map[string]int64            `json:"cleaned,omitempty"` // Cache directory -> bytes left after cleanup
+}
+
+// DefaultHistory returns the history file of app under its state directory.
+func DefaultHistory(app string) History {
+	return History{Path: filepath.Join(DefaultStateDir(app), "history.jsonl")}
+}
+
+// Append adds rec as a new line at the end of the history file.
+func (h History) Append(rec HistoryRecord) error {
+	if err := EnsureDir(h.Path); err != nil {
+		return err
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads all records, oldest first. Lines that fail to parse, such as a
+// partially written last line, are skipped.
+func (h History) Load() ([]HistoryRecord, error) {
+	f, err := os.Open(h.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var recs []HistoryRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		var rec HistoryRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].When.Before(recs[j].When) })
+	return recs, sc.Err()
+}
+
+// GrowthRow is the change in size of one key of a dimension across the history.
+type GrowthRow struct {
+	Key    string    `json:"key"`
+	First  int64     `json:"first_bytes"`
+	Last   int64     `json:"last_bytes"`
+	Since  time.Time `json:"since"`
+	PerDay float64   `json:"bytes_per_day"`
+}
+
+// Growth compares the first and latest size recorded for every key of dim,
+// largest growth first.
+func Growth(recs []HistoryRecord, dim string) []GrowthRow {
+	rows := map[string]*GrowthRow{}
+	last := map[string]time.Time{}
+	for _, rec := range recs {
+		for key, n := range rec.Groups[dim] {
+			row, ok := rows[key]
+			if !ok {
+				row = &GrowthRow{Key: key, First: n, Since: rec.When}
+				rows[key] = row
+			}
+			row.Last = n
+			last[key] = rec.When
+		}
+	}
+	out := make([]GrowthRow, 0, len(rows))
+	for key, row := range rows {
+		row.PerDay = perDay(row.Last-row.First, last[key].Sub(row.Since))
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if di, dj := out[i].Last-out[i].First, out[j].Last-out[j].First; di != dj {
+			return di > dj
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+// RegrowthRow is how quickly a cache directory has filled up again since it was last cleaned.
+type RegrowthRow struct {
+	Path      string    `json:"path"`
+	CleanedAt time.Time `json:"cleaned_at"`
+	Size      int64     `json:"size_bytes"`
+	PerDay    float64   `json:"bytes_per_day"`
+}
+
+// Regrowth returns the cache directories that were cleaned and later scanned again,
+// fastest regrowing first.
+func Regrowth(recs []HistoryRecord) []RegrowthRow {
+	type state struct {
+		cleanedAt time.Time
+		base      int64
+		row       *RegrowthRow
+	}
+	states := map[string]*state{}
+	for _, rec := range recs {
+		for path, n := range rec.Paths {
+			st, ok := states[path]
+			if !ok || st.cleanedAt.IsZero() || !rec.When.After(st.cleanedAt) {
+				continue
+			}
+			st.row = &RegrowthRow{Path: path, CleanedAt: st.cleanedAt, Size: n, PerDay: perDay(n-st.base, rec.When.Sub(st.cleanedAt))}
+		}
+		for path, left := range rec.Cleaned {
+			states[path] = &state{cleanedAt: rec.When, base: left}
+		}
+	}
+	out := []RegrowthRow{}
+	for _, st := range states {
+		if st.row != nil {
+			out = append(out, *st.row)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].PerDay != out[j].PerDay {
+			return out[i].PerDay > out[j].PerDay
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// perDay converts a change over d into a daily rate; spans under an hour yield 0.
+func perDay(delta int64, d time.Duration) float64 {
+	if d < time.Hour {
+		return 0
+	}
+	return float64(delta) / d.Hours() * 24
+}
+
+// HistorySummary is everything the history subcommands report.
+type HistorySummary struct {
+	Runs     []HistoryRecord        `json:"runs"`
+	Growth   map[string][]GrowthRow `json:"growth"`
+	Regrowth []RegrowthRow          `json:"regrowth"`
+}
+
+// Summarize computes growth for every dimension in dims and the regrowth of cleaned caches.
+func Summarize(recs []HistoryRecord, dims []string) HistorySummary {
+	sum := HistorySummary{Runs: recs, Growth: map[string][]GrowthRow{}, Regrowth: Regrowth(recs)}
+	if sum.Runs == nil {
+		sum.Runs = []HistoryRecord{}
+	}
+	for _, dim := range dims {
+		sum.Growth[dim] = Growth(recs, dim)
+	}
+	return sum
+}
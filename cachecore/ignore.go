@@ -0,0 +1,263 @@
+package cachecore
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IgnoreFileName is the per-directory ignore file every scan honours. Its
+// lines use .gitignore syntax relative to the directory holding it.
+const IgnoreFileName = ".cachecleanerignore"
+
+// DefaultIgnorePath returns ~/.config/cachecleaner/ignore, the global ignore
+// file shared by all tools, or "" when the home directory is unknown.
+func DefaultIgnorePath() string {
+	h, _ := os.UserHomeDir()
+	if h == "" {
+		return ""
+	}
+	return filepath.Join(h, ".config", "cachecleaner", "ignore")
+}
+
+// ExcludedPath is a path a scan skipped and the rule that excluded it.
+type ExcludedPath struct {
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+	Source  string `json:"source"` // "--exclude", "config" or the ignore file the pattern came from
+}
+
+// ignoreRule is one pattern line. Patterns from an ignore file are already
+// anchored to its directory, so every rule is matched with MatchPath.
+type ignoreRule struct {
+	glob    string
+	line    string // As written, for ExcludedPath.Pattern
+	negate  bool
+	dirOnly bool
+	source  string
+}
+
+// Excluder decides which paths a scan skips, from --exclude patterns, config
+// patterns, the global ignore file and the .cachecleanerignore files in the
+// path's directory and its parents. It remembers every path it excluded so
+// that a report can list them. A nil Excluder excludes nothing.
+type Excluder struct {
+	rules []ignoreRule // Apply everywhere, before any ignore file's rules
+
+	mu       sync.Mutex
+	dirs     map[string][]ignoreRule // Rules of each directory's ignore file, read on first use
+	excluded []ExcludedPath
+}
+
+// NewExcluder returns an Excluder with the rules of the global ignore file at
+// path; a missing file, or an empty path, adds none.
+func NewExcluder(path string) (*Excluder, error) {
+	e := &Excluder{dirs: map[string][]ignoreRule{}}
+	if path == "" {
+		return e, nil
+	}
+	lines, err := readIgnoreFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e.Add(path, lines...)
+	return e, nil
+}
+
+// Add adds patterns that apply everywhere, in .gitignore syntax: "!" re-includes
+// a path, a trailing "/" only matches directories, and patterns match as in
+// MatchPath, so "/mnt/*" is absolute and "node_modules" matches at any depth.
+// Later patterns win over earlier ones.
+func (e *Excluder) Add(source string, patterns ...string) {
+	for _, p := range patterns {
+		if r, ok := parseIgnoreLine(p, "", source); ok {
+			e.rules = append(e.rules, r)
+		}
+	}
+}
+
+// Skip reports whether a scan should skip p, and records it if so. The last
+// matching rule wins, and the rules of deeper ignore files win over those of
+// their parents, as with .gitignore.
+func (e *Excluder) Skip(p string, isDir bool) bool {
+	if e == nil {
+		return false
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	var match *ignoreRule
+	check := func(rules []ignoreRule) {
+		for i := range rules {
+			r := &rules[i]
+			if (!r.dirOnly || isDir) && MatchPath(r.glob, p) {
+				match = r
+			}
+		}
+	}
+	check(e.rules)
+	for _, dir := range parentDirs(p) {
+		check(e.dirRules(dir))
+	}
+	if match == nil || match.negate {
+		return false
+	}
+	e.mu.Lock()
+	e.excluded = append(e.excluded, ExcludedPath{Path: p, Pattern: match.line, Source: match.source})
+	e.mu.Unlock()
+	return true
+}
+
+// Excluded returns the paths skipped so far, in the order they were skipped.
+func (e *Excluder) Excluded() []ExcludedPath {
+	if e == nil {
+		return []ExcludedPath{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]ExcludedPath{}, e.excluded...)
+}
+
+// dirRules returns the rules of dir's ignore file. A file that cannot be read
+// is reported once on stderr and ignored.
+func (e *Excluder) dirRules(dir string) []ignoreRule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if rules, ok := e.dirs[dir]; ok {
+		return rules
+	}
+	path := filepath.Join(dir, IgnoreFileName)
+	lines, err := readIgnoreFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	var rules []ignoreRule
+	for _, line := range lines {
+		if r, ok := parseIgnoreLine(line, dir, path); ok {
+			rules = append(rules, r)
+		}
+	}
+	e.dirs[dir] = rules
+	return rules
+}
+
+// readIgnoreFile returns the lines of an ignore file; a missing file has none.
+func readIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// parseIgnoreLine parses one .gitignore-style line. With dir set, a pattern
+// with a slash before its end is relative to dir and one without matches base
+// names anywhere below dir. Blank lines and "#" comments yield no rule.
+func parseIgnoreLine(line, dir, source string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	r := ignoreRule{line: line, source: source}
+	glob := line
+	if rest, ok := strings.CutPrefix(glob, "!"); ok {
+		r.negate, glob = true, rest
+	}
+	if rest, ok := strings.CutSuffix(glob, "/"); ok {
+		r.dirOnly, glob = true, rest
+	}
+	if glob == "" {
+		return ignoreRule{}, false
+	}
+	if dir != "" {
+		base := filepath.ToSlash(dir)
+		if strings.Contains(glob, "/") {
+			glob = strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(glob, "/")
+		} else {
+			glob = strings.TrimSuffix(base, "/") + "/**/" + glob
+		}
+	}
+	r.glob = glob
+	return r, true
+}
+
+// parentDirs returns the directories containing p, outermost first.
+func parentDirs(p string) []string {
+	var dirs []string
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	for i, j := 0, len(dirs)-1; i < j; i, j = i+1, j-1 {
+		dirs[i], dirs[j] = dirs[j], dirs[i]
+	}
+	return dirs
+}
+
+// ExcludedSummary describes how many paths were excluded by which pattern,
+// most first, e.g. "12 paths excluded: 10 by node_modules (--exclude), 2 by
+// /mnt/* (config)". It returns "" when nothing was excluded.
+func ExcludedSummary(excluded []ExcludedPath) string {
+	if len(excluded) == 0 {
+		return ""
+	}
+	type key struct{ pattern, source string }
+	counts := map[key]int{}
+	var keys []key
+	for _, x := range excluded {
+		k := key{x.Pattern, x.Source}
+		if counts[k] == 0 {
+			keys = append(keys, k)
+		}
+		counts[k]++
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return counts[keys[i]] > counts[keys[j]] })
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%d by %s (%s)", counts[k], k.pattern, k.source)
+	}
+	noun := "paths"
+	if len(excluded) == 1 {
+		noun = "path"
+	}
+	return fmt.Sprintf("%d %s excluded: %s", len(excluded), noun, strings.Join(parts, ", "))
+}
+
+// Patterns is a flag that may be given several times, each use adding one
+// pattern.
+type Patterns []string
+
+func (p *Patterns) String() string { return strings.Join(*p, ",") }
+
+func (p *Patterns) Set(v string) error {
+	*p = append(*p, v)
+	return nil
+}
+
+// PatternsFlag defines a repeatable pattern flag on the default flag set.
+func PatternsFlag(name, usage string) *Patterns {
+	p := &Patterns{}
+	flag.Var(p, name, usage)
+	return p
+}
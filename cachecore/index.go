@@ -0,0 +1,231 @@
+package cachecore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Index remembers the totals of every directory a Sizer has walked, keyed by the
+// directory's own modification time, and persists them between runs. A directory whose
+// mtime is unchanged has had no entry added, removed or renamed, so its file totals and
+// list of subdirectories are reused without reading it or stat-ing its files. Only the
+// subdirectories themselves are stat-ed, so an unchanged subtree costs one lstat per
+// directory. Files rewritten in place without touching their directory are not noticed
+// until the directory changes, in sizes or in Finding.ModMax; skip the index to force a
+// full walk, and always skip it when ModMax decides what to delete.
+type Index struct {
+	Path string
+
+	mu      sync.Mutex
+	dirs    map[string]indexDir
+	visited map[string]bool
+	roots   []string
+	dirty   bool
+}
+
+// racyWindow is how old a directory's mtime must be before its totals are cached.
+const racyWindow = 2 * time.Second
+
+// indexDir is the cached state of one directory; only its direct files are summed.
+type indexDir struct {
+	MTime    int64       `json:"m"`           // Directory mtime in Unix nanoseconds
+	Apparent int64       `json:"a"`           // Sum of file sizes
+	Disk     int64       `json:"d"`           // Allocated bytes of files with a single link
+	Files    int         `json:"n"`           // Number of files
+	ModMax   int64       `json:"t,omitempty"` // Newest file mtime in Unix nanoseconds
+	Subdirs  []string    `json:"s,omitempty"` // Names of subdirectories
+	Links    []indexLink `json:"l,omitempty"` // Files with several hard links
+}
+
+type indexLink struct {
+	Dev   uint64 `json:"d"`
+	Ino   uint64 `json:"i"`
+	Bytes int64  `json:"b"`
+}
+
+// DefaultIndexPath returns the size index file of app under its state directory.
+func DefaultIndexPath(app string) string {
+	return filepath.Join(DefaultStateDir(app), "size-index.json")
+}
+
+// LoadIndex reads the index at path. A missing or unreadable index yields an empty
+// one, since the index is only a cache.
+func LoadIndex(path string) *Index {
+	x := &Index{Path: path, dirs: map[string]indexDir{}, visited: map[string]bool{}}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return x
+	}
+	if err := json.Unmarshal(b, &x.dirs); err != nil || x.dirs == nil {
+		x.dirs = map[string]indexDir{}
+	}
+	return x
+}
+
+// Save writes the index if anything changed. Entries below a root walked in this run
+// that were not visited again, such as deleted directories, are dropped first, as are
+// entries for directories that no longer exist, such as those of a removed cache root.
+func (x *Index) Save() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for dir := range x.dirs {
+		if x.visited[dir] {
+			continue
+		}
+		if x.underRoot(dir) {
+			delete(x.dirs, dir)
+			x.dirty = true
+			continue
+		}
+		if _, err := os.Lstat(dir); os.IsNotExist(err) {
+			delete(x.dirs, dir)
+			x.dirty = true
+		}
+	}
+	if !x.dirty {
+		return nil
+	}
+	if err := EnsureDir(x.Path); err != nil {
+		return err
+	}
+	b, err := json.Marshal(x.dirs)
+	if err != nil {
+		return err
+	}
+	tmp := x.Path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, x.Path); err != nil {
+		return err
+	}
+	x.dirty = false
+	return nil
+}
+
+// underRoot reports whether dir is, or is below, a root walked in this run.
+func (x *Index) underRoot(dir string) bool {
+	for _, root := range x.roots {
+		if dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// inspect measures root like the package-level inspect, reusing unchanged directories.
+// A root that is gone or no longer a directory is still recorded, so Save drops its
+// entries.
+func (x *Index) inspect(root string, exclude *Excluder) (Finding, []linkedFile, error) {
+	fi, err := os.Stat(root)
+	x.mu.Lock()
+	x.roots = append(x.roots, root)
+	x.mu.Unlock()
+	if err != nil || !fi.IsDir() {
+		return inspect(root, exclude)
+	}
+
+	f := Finding{Path: root}
+	var links []linkedFile
+	seen := make(map[fileID]bool)
+	var walk func(dir string, info os.FileInfo)
+	walk = func(dir string, info os.FileInfo) {
+		d, err := x.dir(dir, info)
+		if err != nil && f.Err == "" {
+			f.Err = err.Error()
+		}
+		f.Items += d.Files
+		f.ApparentBytes += d.Apparent
+		f.DiskBytes += d.Disk
+		if t := time.Unix(0, d.ModMax); d.ModMax != 0 && t.After(f.ModMax) {
+			f.ModMax = t
+		}
+		for _, l := range d.Links {
+			id := fileID{dev: l.Dev, ino: l.Ino}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			links = append(links, linkedFile{id: id, bytes: l.Bytes})
+			f.DiskBytes += l.Bytes
+		}
+		for _, name := range d.Subdirs {
+			sub := filepath.Join(dir, name)
+			if exclude.Skip(sub, true) {
+				f.Excluded++
+				continue
+			}
+			si, err := os.Lstat(sub)
+			if err != nil {
+				if f.Err == "" {
+					f.Err = err.Error()
+				}
+				continue
+			}
+			walk(sub, si)
+		}
+	}
+	walk(root, fi)
+	f.SizeBytes = f.ApparentBytes
+	return f, links, nil
+}
+
+// dir returns the totals of a single directory, from the index when its mtime is
+// unchanged and by reading it otherwise.
+func (x *Index) dir(dir string, info os.FileInfo) (indexDir, error) {
+	mtime := info.ModTime().UnixNano()
+	x.mu.Lock()
+	d, ok := x.dirs[dir]
+	x.visited[dir] = true
+	x.mu.Unlock()
+	if ok && d.MTime == mtime {
+		return d, nil
+	}
+
+	d = indexDir{MTime: mtime}
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		// Do not cache a directory that could not be read
+		return d, err
+	}
+	seen := make(map[fileID]bool)
+	for _, ent := range ents {
+		if ent.IsDir() {
+			d.Subdirs = append(d.Subdirs, ent.Name())
+			continue
+		}
+		fi, e := ent.Info()
+		if e != nil {
+			continue
+		}
+		d.Files++
+		d.Apparent += fi.Size()
+		if t := fi.ModTime().UnixNano(); t > d.ModMax {
+			d.ModMax = t
+		}
+		disk, id, linked := diskUsage(fi)
+		if linked {
+			if !seen[id] {
+				seen[id] = true
+				d.Links = append(d.Links, indexLink{Dev: id.dev, Ino: id.ino, Bytes: disk})
+			}
+			continue
+		}
+		d.Disk += disk
+	}
+
+	// Like git's racily clean check: a directory changed within the mtime granularity
+	// could change again without its mtime moving, so it is not cached yet
+	if time.Since(info.ModTime()) < racyWindow {
+		return d, nil
+	}
+	x.mu.Lock()
+	x.dirs[dir] = d
+	x.dirty = true
+	x.mu.Unlock()
+	return d, nil
+}
@@ -0,0 +1,103 @@
+package cachecore
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Finding is the size measurement of a single path. Tools embed it in their
+// own finding types to attach tool-specific context (project, repo, pattern).
+//
+// SizeBytes is the figure used for totals and tables: the apparent size by
+// default, or the on-disk size when measured by a Sizer with OnDisk set.
+type Finding struct {
+	Path          string    `json:"path"`
+	SizeBytes     int64     `json:"size_bytes"`
+	ApparentBytes int64     `json:"apparent_bytes"` // Sum of file sizes
+	DiskBytes     int64     `json:"disk_bytes"`     // Allocated blocks, each hard-linked file counted once
+	Items         int       `json:"items"`
+	Err           string    `json:"error,omitempty"`
+	ModMax        time.Time `json:"latest_mtime"`
+	Excluded      int       `json:"excluded,omitempty"` // Subdirectories skipped because a Sizer's Exclude ruled them out
+}
+
+// fileID identifies a file across hard links.
+type fileID struct {
+	dev, ino uint64
+}
+
+// linkedFile is a file with more than one hard link, remembered so that a
+// Sizer can count it only once across all the paths it measures.
+type linkedFile struct {
+	id    fileID
+	bytes int64
+}
+
+// Inspect measures root. For a directory it walks the whole tree, summing
+// file sizes and counting files; the first walk error is recorded in Err but
+// does not stop the walk. ModMax is the newest file modification time seen.
+func Inspect(root string) (Finding, error) {
+	f, _, err := inspect(root, nil)
+	return f, err
+}
+
+// inspect measures root, skipping the subdirectories exclude rules out.
+func inspect(root string, exclude *Excluder) (Finding, []linkedFile, error) {
+	f := Finding{Path: root}
+	fi, err := os.Stat(root)
+	if err != nil {
+		return f, nil, err
+	}
+	if !fi.IsDir() {
+		disk, id, linked := diskUsage(fi)
+		f.Items = 1
+		f.SizeBytes = fi.Size()
+		f.ApparentBytes = fi.Size()
+		f.DiskBytes = disk
+		f.ModMax = fi.ModTime()
+		if linked {
+			return f, []linkedFile{{id: id, bytes: disk}}, nil
+		}
+		return f, nil, nil
+	}
+	var links []linkedFile
+	seen := make(map[fileID]bool)
+	errWalk := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if f.Err == "" {
+				f.Err = err.Error()
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if p != root && exclude.Skip(p, true) {
+				f.Excluded++
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, e := d.Info()
+		if e != nil {
+			return nil
+		}
+		f.Items++
+		f.ApparentBytes += info.Size()
+		if info.ModTime().After(f.ModMax) {
+			f.ModMax = info.ModTime()
+		}
+		disk, id, linked := diskUsage(info)
+		if linked {
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+			links = append(links, linkedFile{id: id, bytes: disk})
+		}
+		f.DiskBytes += disk
+		return nil
+	})
+	f.SizeBytes = f.ApparentBytes
+	return f, links, errWalk
+}
@@ -0,0 +1,239 @@
+package cachecore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+const manifestName = "manifest.json"
+
+// QuarantineDirPrefix starts the name of the hidden holder directory used when a directory
+// cannot be moved into the quarantine area. Scanners should not descend into it.
+const QuarantineDirPrefix = ".quarantine-"
+
+// renameToArea moves a directory into the quarantine area; tests replace it to simulate
+// a cross-device move.
+var renameToArea = os.Rename
+
+// Quarantine is a holding area for directories a cleaner has removed but not yet deleted.
+// Each cleanup run gets its own subdirectory with a manifest of the original paths,
+// so a run can be restored or purged as a whole.
+type Quarantine struct {
+	Dir string
+}
+
+// QuarantineEntry records where a quarantined directory came from and where it is held.
+type QuarantineEntry struct {
+	Original  string `json:"original"`
+	Held      string `json:"held"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// QuarantineRun is the set of directories moved aside by one cleanup run.
+type QuarantineRun struct {
+	ID      string            `json:"id"`
+	When    time.Time         `json:"when"`
+	Entries []QuarantineEntry `json:"entries"`
+
+	dir string
+}
+
+// TotalBytes returns the combined size of the directories held by the run.
+func (r *QuarantineRun) TotalBytes() int64 {
+	var total int64
+	for _, e := range r.Entries {
+		total += e.SizeBytes
+	}
+	return total
+}
+
+// Begin starts a new run in the quarantine area.
+func (q Quarantine) Begin(now time.Time) (*QuarantineRun, error) {
+	if err := os.MkdirAll(q.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	id := now.Format("20060102-150405")
+	for n := 2; ; n++ {
+		dir := filepath.Join(q.Dir, id)
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			r := &QuarantineRun{ID: id, When: now, Entries: []QuarantineEntry{}, dir: dir}
+			return r, r.save()
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		id = fmt.Sprintf("%s-%d", now.Format("20060102-150405"), n)
+	}
+}
+
+// Hold moves path into the run with a rename, so it is instant and nothing is copied.
+// When path is on another filesystem than the quarantine area, it is instead moved into
+// a hidden .quarantine-<run> holder in its own parent directory, which is always the same
+// filesystem. The holder contains a .gitignore that ignores everything, so it does not
+// show up as untracked work in git. The manifest is rewritten after every move so an
+// interrupted run can still be restored.
+func (r *QuarantineRun) Hold(path string, size int64) error {
+	held := filepath.Join(r.dir, fmt.Sprintf("%d-%s", len(r.Entries)+1, filepath.Base(path)))
+	err := renameToArea(path, held)
+	if errors.Is(err, syscall.EXDEV) {
+		holder := filepath.Join(filepath.Dir(path), QuarantineDirPrefix+r.ID)
+		if err := makeHolder(holder); err != nil {
+			return err
+		}
+		held = filepath.Join(holder, filepath.Base(path))
+		if err = os.Rename(path, held); err != nil {
+			removeHolder(holder)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	r.Entries = append(r.Entries, QuarantineEntry{Original: path, Held: held, SizeBytes: size})
+	return r.save()
+}
+
+// Restore moves every held directory back to its original path. Entries whose original
+// path has been recreated in the meantime are left in quarantine and reported as errors.
+// The run is removed once all of its entries are restored.
+func (r *QuarantineRun) Restore() []error {
+	var errs []error
+	var remaining []QuarantineEntry
+	for _, e := range r.Entries {
+		if _, err := os.Lstat(e.Original); err == nil {
+			errs = append(errs, fmt.Errorf("%s: already exists, left in quarantine", e.Original))
+			remaining = append(remaining, e)
+			continue
+		}
+		if err := EnsureDir(e.Original); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Original, err))
+			remaining = append(remaining, e)
+			continue
+		}
+		if err := os.Rename(e.Held, e.Original); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Original, err))
+			remaining = append(remaining, e)
+			continue
+		}
+		r.releaseHolder(e)
+	}
+	r.Entries = remaining
+	if len(remaining) == 0 {
+		if err := os.RemoveAll(r.dir); err != nil {
+			errs = append(errs, err)
+		}
+		return errs
+	}
+	if err := r.save(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Purge permanently deletes the held directories and the run itself. Entries that
+// cannot be deleted stay in the manifest so the run can be purged or restored later.
+func (r *QuarantineRun) Purge() error {
+	var errs []error
+	var remaining []QuarantineEntry
+	for _, e := range r.Entries {
+		if err := os.RemoveAll(e.Held); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Held, err))
+			remaining = append(remaining, e)
+			continue
+		}
+		r.releaseHolder(e)
+	}
+	r.Entries = remaining
+	if len(remaining) == 0 {
+		return errors.Join(append(errs, os.RemoveAll(r.dir))...)
+	}
+	return errors.Join(append(errs, r.save())...)
+}
+
+// Close removes the run if nothing was held in it, so a cleanup where every move
+// failed does not leave an empty run behind.
+func (r *QuarantineRun) Close() error {
+	if len(r.Entries) > 0 {
+		return nil
+	}
+	return os.RemoveAll(r.dir)
+}
+
+// releaseHolder removes the holder directory of an entry held outside the quarantine
+// area once it no longer holds anything.
+func (r *QuarantineRun) releaseHolder(e QuarantineEntry) {
+	if holder := filepath.Dir(e.Held); holder != r.dir {
+		removeHolder(holder)
+	}
+}
+
+// makeHolder creates a holder directory that git ignores entirely.
+func makeHolder(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*\n"), 0o644)
+}
+
+// removeHolder deletes a holder directory if it is empty apart from its .gitignore.
+func removeHolder(dir string) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, ent := range ents {
+		if ent.Name() != ".gitignore" {
+			return
+		}
+	}
+	_ = os.RemoveAll(dir)
+}
+
+// Runs returns the runs in the quarantine area, oldest first.
+func (q Quarantine) Runs() ([]*QuarantineRun, error) {
+	ents, err := os.ReadDir(q.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []*QuarantineRun
+	for _, ent := range ents {
+		if !ent.IsDir() {
+			continue
+		}
+		dir := filepath.Join(q.Dir, ent.Name())
+		b, err := os.ReadFile(filepath.Join(dir, manifestName))
+		if err != nil {
+			continue // Not a run directory
+		}
+		var r QuarantineRun
+		if err := json.Unmarshal(b, &r); err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		r.dir = dir
+		runs = append(runs, &r)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].When.Before(runs[j].When) })
+	return runs, nil
+}
+
+// save writes the manifest via a temporary file so it is never left half written.
+func (r *QuarantineRun) save() error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(r.dir, manifestName+".tmp")
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(r.dir, manifestName))
+}
@@ -0,0 +1,27 @@
+package cachecore
+
+import (
+	"os"
+	"runtime"
+	"time"
+)
+
+// Envelope holds the fields every tool's JSON report starts with, so that
+// reports from all tools can be ingested the same way.
+type Envelope struct {
+	Hostname string    `json:"hostname"`
+	OS       string    `json:"os"`
+	Arch     string    `json:"arch"`
+	DryRun   bool      `json:"dry_run"`
+	When     time.Time `json:"when"`
+	SizeMode string    `json:"size_mode,omitempty"` // SizeModeApparent or SizeModeDisk
+}
+
+// NewEnvelope describes the current host and run.
+func NewEnvelope(dryRun bool) Envelope {
+	e := Envelope{OS: runtime.GOOS, Arch: runtime.GOARCH, DryRun: dryRun, When: time.Now()}
+	if h, _ := os.Hostname(); h != "" {
+		e.Hostname = h
+	}
+	return e
+}
@@ -0,0 +1,108 @@
+package cachecore
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DefaultJobs is the default number of concurrent directory walks.
+func DefaultJobs() int { return runtime.NumCPU() }
+
+// Size modes reported in Envelope.SizeMode.
+const (
+	SizeModeApparent = "apparent"
+	SizeModeDisk     = "disk"
+)
+
+// Sizer measures many paths at once using a bounded pool of walkers.
+type Sizer struct {
+	Jobs   int  // Max concurrent walks; <= 0 means DefaultJobs()
+	OnDisk bool // Report allocated blocks (like du) as SizeBytes instead of apparent size
+
+	// Index, if set, reuses the totals of directories unchanged since an earlier walk.
+	Index *Index
+
+	// Exclude, if set, skips the subdirectories it rules out; the paths given to
+	// InspectAll are always measured. Finding.Excluded counts what was skipped.
+	Exclude *Excluder
+
+	// Progress, if set, is called after each walk finishes with the number of paths
+	// done so far. Calls are serialized, so it may print without extra locking.
+	Progress func(done, total int)
+}
+
+// Mode returns SizeModeDisk or SizeModeApparent.
+func (s Sizer) Mode() string {
+	if s.OnDisk {
+		return SizeModeDisk
+	}
+	return SizeModeApparent
+}
+
+// InspectAll runs Inspect on every path and returns the findings and errors
+// in the same order as paths, regardless of which walk finishes first.
+//
+// A file hard-linked from several of the paths is counted in DiskBytes only
+// for the first of those paths, so the on-disk figures add up to what would
+// actually be freed.
+func (s Sizer) InspectAll(paths []string) ([]Finding, []error) {
+	findings := make([]Finding, len(paths))
+	links := make([][]linkedFile, len(paths))
+	errs := make([]error, len(paths))
+	if len(paths) == 0 {
+		return findings, errs
+	}
+
+	jobs := s.Jobs
+	if jobs <= 0 {
+		jobs = DefaultJobs()
+	}
+	if jobs > len(paths) {
+		jobs = len(paths)
+	}
+
+	next := make(chan int)
+	var mu sync.Mutex
+	var done int
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if s.Index != nil {
+					findings[i], links[i], errs[i] = s.Index.inspect(paths[i], s.Exclude)
+				} else {
+					findings[i], links[i], errs[i] = inspect(paths[i], s.Exclude)
+				}
+				if s.Progress != nil {
+					mu.Lock()
+					done++
+					s.Progress(done, len(paths))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range paths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	// Attribute shared hard links in path order so the result does not depend on scheduling
+	seen := make(map[fileID]bool)
+	for i := range findings {
+		for _, l := range links[i] {
+			if seen[l.id] {
+				findings[i].DiskBytes -= l.bytes
+				continue
+			}
+			seen[l.id] = true
+		}
+		if s.OnDisk {
+			findings[i].SizeBytes = findings[i].DiskBytes
+		}
+	}
+	return findings, errs
+}
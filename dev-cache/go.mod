@@ -3,8 +3,8 @@ module dev-cache
 go 1.21
 
 require (
+	cachecore v0.0.0
 	github.com/olekukonko/tablewriter v1.1.3
-	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -19,4 +19,7 @@ require (
 	github.com/olekukonko/errors v1.1.0 // indirect
 	github.com/olekukonko/ll v0.1.4-0.20260115111900-9e59c2286df0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace cachecore => ../cachecore
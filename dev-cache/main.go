@@ -8,13 +8,13 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
-	"runtime"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
-	yaml "gopkg.in/yaml.v3"
+
+	"cachecore"
 )
 
 // ----- Version info -----
@@ -26,15 +26,26 @@ var (
 
 // ----- CLI flags -----
 var (
-	flagClean  = flag.Bool("clean", false, "Delete found cache directories")
-	flagYes    = flag.Bool("yes", false, "Skip confirmation prompt for cleanup")
-	flagJSON   = flag.Bool("json", false, "Output results as JSON")
-	flagConfig = flag.String("config", defaultConfigPath(), "Path to YAML config")
-	flagInit   = flag.Bool("init", false, "Write a starter config to --config and exit")
-	flagForce  = flag.Bool("force", false, "Force overwrite existing config (use with --init)")
-	flagScan   = flag.String("scan", "", "Directory to scan (overrides config default)")
-	flagDepth  = flag.Int("depth", 0, "Max scan depth (0 = use config default, overrides config)")
-	flagLangs  = flag.String("languages", "", "Comma-separated list of languages to scan")
+	flagClean        = flag.Bool("clean", false, "Delete found cache directories")
+	flagYes          = flag.Bool("yes", false, "Skip confirmation prompt for cleanup")
+	flagJSON         = flag.Bool("json", false, "Output results as JSON")
+	flagConfig       = flag.String("config", defaultConfigPath(), "Path to YAML config")
+	flagInit         = flag.Bool("init", false, "Write a starter config to --config and exit")
+	flagForce        = flag.Bool("force", false, "Force overwrite existing config (use with --init)")
+	flagScan         = flag.String("scan", "", "Directory to scan (overrides config default)")
+	flagDepth        = flag.Int("depth", 0, "Max scan depth (0 = use config default, overrides config)")
+	flagLangs        = flag.String("languages", "", "Comma-separated list of languages to scan")
+	flagJobs         = flag.Int("jobs", cachecore.DefaultJobs(), "Max cache directories sized concurrently")
+	flagOnDisk       = flag.Bool("on-disk", false, "Report allocated on-disk usage (like du) instead of apparent size")
+	flagOlder        = flag.String("older-than", "", "Only clean caches whose newest file is older than this, e.g. 30d, 2w, 12h (overrides config minAge)")
+	flagInactive     = flag.String("inactive-for", "", "Only clean projects with no git commits within this age, e.g. 60d (overrides config minCommitAge)")
+	flagRequireClean = flag.Bool("require-clean", false, "Only clean projects whose git worktree has no uncommitted changes, untracked files or stashes")
+	flagIgnoredOnly  = flag.Bool("ignored-only", false, "Only clean cache directories ignored by the project's .gitignore rules; tracked ones are protected")
+	flagNoIndex      = flag.Bool("no-index", false, "Walk every cache directory instead of reusing unchanged ones from the size index")
+	flagNoHistory    = flag.Bool("no-history", false, "Do not append this run to the scan history (see history)")
+	flagExclude      = cachecore.PatternsFlag("exclude", "Skip directories matching this pattern while scanning, e.g. Dropbox or /Volumes/* (repeatable)")
+	flagOneFS        = flag.Bool("one-file-system", true, "Stay on the scan path's filesystem and skip mount points below it (--one-file-system=false to cross them)")
+	flagQuarantine   = flag.Bool("quarantine", false, "Move cache directories to a quarantine area instead of deleting them (see restore and purge)")
 )
 
 // ----- Config types -----
@@ -46,9 +57,14 @@ type Config struct {
 }
 
 type Options struct {
-	DefaultScanPath string `yaml:"defaultScanPath"`
-	MaxDepth        int    `yaml:"maxDepth"`
-	DetectLanguage  bool   `yaml:"detectLanguage"` // If true, detect language per directory and search only relevant patterns
+	DefaultScanPath string   `yaml:"defaultScanPath"`
+	MaxDepth        int      `yaml:"maxDepth"`
+	DetectLanguage  bool     `yaml:"detectLanguage"`                 // If true, detect language per directory and search only relevant patterns
+	MinAge          string   `yaml:"minAge,omitempty"`               // Only clean caches whose newest file is older than this (e.g. "30d"); empty = no limit
+	MinCommitAge    string   `yaml:"minCommitAge,omitempty"`         // Only clean projects whose last git commit is older than this (e.g. "60d")
+	RequireClean    bool     `yaml:"requireCleanWorktree,omitempty"` // Only clean projects with no uncommitted changes, untracked files or stashes
+	IgnoredOnly     bool     `yaml:"ignoredOnly,omitempty"`          // Only clean cache directories that the project's .gitignore rules ignore
+	Exclude         []string `yaml:"exclude,omitempty"`              // Path globs skipped while scanning, like --exclude (e.g. "/Volumes/*", "Dropbox")
 }
 
 type Language struct {
@@ -62,102 +78,79 @@ type Language struct {
 // ----- Finding types -----
 
 type Finding struct {
-	Path        string    `json:"path"`
-	ProjectRoot string    `json:"project_root,omitempty"` // Directory where language was detected
-	SizeBytes   int64     `json:"size_bytes"`
-	Items       int       `json:"items"`
-	Pattern     string    `json:"pattern"`
-	Language    string    `json:"language"`
-	Err         string    `json:"error,omitempty"`
-	ModMax      time.Time `json:"latest_mtime"`
+	cachecore.Finding
+	ProjectRoot string `json:"project_root,omitempty"` // Directory where language was detected
+	Pattern     string `json:"pattern"`
+	Language    string `json:"language"`
+	AgeDays     int    `json:"age_days"`         // Days since the newest file in the cache was modified
+	Eligible    bool   `json:"eligible"`         // Whether --clean may delete this cache directory
+	Reason      string `json:"reason,omitempty"` // Why the cache directory is or is not eligible
+
+	Git       *cachecore.GitActivity `json:"git,omitempty"`       // Git state of the project; only read when an activity policy is set
+	VCS       string                 `json:"vcs,omitempty"`       // ignored, tracked or untracked; only checked with --ignored-only
+	Protected bool                   `json:"protected,omitempty"` // Directory holds files tracked by git and is never cleaned
 }
 
 type Report struct {
-	Hostname string    `json:"hostname"`
-	OS       string    `json:"os"`
-	Arch     string    `json:"arch"`
-	DryRun   bool      `json:"dry_run"`
-	When     time.Time `json:"when"`
-	ScanPath string    `json:"scan_path"`
-	MaxDepth int       `json:"max_depth"`
-	Total    int64     `json:"total_bytes"`
-	Findings []Finding `json:"findings"`
-	Warnings []string  `json:"warnings"`
+	cachecore.Envelope
+	ScanPath string `json:"scan_path"`
+	MaxDepth int    `json:"max_depth"`
+	MinAge   string `json:"min_age,omitempty"`
+
+	MinCommitAge string `json:"min_commit_age,omitempty"`
+	RequireClean bool   `json:"require_clean_worktree,omitempty"`
+	IgnoredOnly  bool   `json:"ignored_only,omitempty"`
+
+	Total    int64                    `json:"total_bytes"`
+	Findings []Finding                `json:"findings"`
+	Excluded []cachecore.ExcludedPath `json:"excluded"` // Directories the scan skipped
+	Warnings []string                 `json:"warnings"`
 }
 
 // ----- Utilities -----
 
-func defaultConfigPath() string {
-	h, _ := os.UserHomeDir()
-	if h == "" {
-		return "./config.yaml"
-	}
-	return filepath.Join(h, ".config", "dev-cache", "config.yaml")
-}
-
-func ensureDir(p string) error { return os.MkdirAll(filepath.Dir(p), 0o755) }
+func defaultConfigPath() string { return cachecore.DefaultConfigPath("dev-cache") }
 
-func home() string           { h, _ := os.UserHomeDir(); return h }
-func expand(p string) string { return os.ExpandEnv(strings.ReplaceAll(p, "~", home())) }
+var (
+	ensureDir        = cachecore.EnsureDir
+	expand           = cachecore.Expand
+	human            = cachecore.Human
+	checkVersionFlag = cachecore.CheckVersionFlag
+)
 
-func checkVersionFlag() bool {
-	for _, arg := range os.Args[1:] {
-		if arg == "-version" || arg == "--version" {
-			return true
-		}
-	}
-	return false
+// newQuarantine returns the holding area used by --quarantine, restore and purge.
+func newQuarantine() cachecore.Quarantine {
+	return cachecore.Quarantine{Dir: filepath.Join(cachecore.DefaultStateDir("dev-cache"), "quarantine")}
 }
 
-func human(n int64) string {
-	if n < 1024 {
-		return fmt.Sprintf("%d B", n)
-	}
-	units := []string{"KB", "MB", "GB", "TB"}
-	v := float64(n)
-	for i, u := range units {
-		v /= 1024
-		if v < 1024 || i == len(units)-1 {
-			return fmt.Sprintf("%.2f %s", v, u)
-		}
+// newSizer returns a directory sizer configured from --jobs, --on-disk, --no-index and the exclusions.
+// With an age limit the index is bypassed: it keeps a directory's newest-file time until
+// the directory itself changes, so a cache whose files are rewritten in place would look old.
+func newSizer() cachecore.Sizer {
+	s := cachecore.Sizer{Jobs: *flagJobs, OnDisk: *flagOnDisk, Index: sizeIndex, Exclude: excluder}
+	if ageLimit > 0 {
+		s.Index = nil
 	}
-	return fmt.Sprintf("%.2f TB", v/1024)
+	return s
 }
 
+// sizeIndex caches directory totals between runs; nil with --no-index.
+var sizeIndex *cachecore.Index
+
+// ageLimit is the minimum age of caches to clean (--older-than or minAge); 0 for none.
+var ageLimit time.Duration
+
+// excluder skips the directories ruled out by --exclude, the config and ignore
+// files while scanning; nil excludes nothing.
+var excluder *cachecore.Excluder
+
+// boundary keeps the scan on the scan path's filesystem; nil with
+// --one-file-system=false.
+var boundary *cachecore.Boundary
+
 func inspectPath(root string) (Finding, error) {
-	f := Finding{Path: root}
-	fi, err := os.Stat(root)
-	if err != nil {
-		return f, err
-	}
-	if !fi.IsDir() {
-		f.Items = 1
-		f.SizeBytes = fi.Size()
-		f.ModMax = fi.ModTime()
-		return f, nil
-	}
-	errWalk := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			if f.Err == "" {
-				f.Err = err.Error()
-			}
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		info, e := d.Info()
-		if e != nil {
-			return nil
-		}
-		f.Items++
-		f.SizeBytes += info.Size()
-		if info.ModTime().After(f.ModMax) {
-			f.ModMax = info.ModTime()
-		}
-		return nil
-	})
-	return f, errWalk
+	u, err := cachecore.Inspect(root)
+	return Finding{Finding: u}, err
 }
 
 // isCacheDirectory returns true if the finding represents a cache directory (has a non-empty pattern)
@@ -182,25 +175,13 @@ func getLanguageForExclusion(detectedLang string, isExcluded bool) string {
 // ----- Config IO -----
 
 func writeStarterConfig(path string, force bool) error {
-	// Check if file exists
-	if _, err := os.Stat(path); err == nil {
-		if !force {
-			return fmt.Errorf("config file already exists at %s. Use --force to overwrite", path)
-		}
-		// Backup existing file with timestamp
-		backupPath := fmt.Sprintf("%s.%s", path, time.Now().Format("20060102-150405"))
-		if err := os.Rename(path, backupPath); err != nil {
-			return fmt.Errorf("failed to backup existing config: %w", err)
-		}
-		fmt.Printf("Existing config backed up to: %s\n", backupPath)
-	}
-
 	starter := Config{
 		Version: 1,
 		Options: Options{
 			DefaultScanPath: "~/src",
 			MaxDepth:        1,
 			DetectLanguage:  true,
+			Exclude:         []string{"/Volumes/*", "/mnt/*", "Dropbox", "Library/CloudStorage"},
 		},
 		Languages: []Language{
 			{Name: "node", Enabled: true, Priority: 10, Patterns: []string{"node_modules", ".npm", ".yarn", ".pnpm-store"}, Signatures: []string{"package.json", "package-lock.json", "yarn.lock", "pnpm-lock.yaml"}},
@@ -217,27 +198,10 @@ func writeStarterConfig(path string, force bool) error {
 			{Name: "flutter", Enabled: true, Priority: 5, Patterns: []string{"build", ".dart_tool"}, Signatures: []string{"pubspec.yaml", "pubspec.lock"}},
 		},
 	}
-	if err := ensureDir(path); err != nil {
-		return err
-	}
-	b, err := yaml.Marshal(starter)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(path, b, 0o644)
+	return cachecore.WriteConfig(path, force, starter)
 }
 
-func loadConfig(path string) (*Config, error) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	var cfg Config
-	if err := yaml.Unmarshal(b, &cfg); err != nil {
-		return nil, err
-	}
-	return &cfg, nil
-}
+func loadConfig(path string) (*Config, error) { return cachecore.LoadConfig[Config](path) }
 
 // ----- Main -----
 
@@ -247,8 +211,29 @@ func main() {
 		return
 	}
 
+	// Subcommands operate on the quarantine area and take their own flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "restore":
+			os.Exit(runRestore(os.Args[2:]))
+		case "purge":
+			os.Exit(runPurge(os.Args[2:]))
+		case "history":
+			os.Exit(runHistory(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
+	if !*flagNoIndex {
+		sizeIndex = cachecore.LoadIndex(cachecore.DefaultIndexPath("dev-cache"))
+		defer func() {
+			if err := sizeIndex.Save(); err != nil {
+				fmt.Fprintln(os.Stderr, "size index error:", err)
+			}
+		}()
+	}
+
 	if *flagInit {
 		if err := writeStarterConfig(*flagConfig, *flagForce); err != nil {
 			fmt.Println("init error:", err)
@@ -278,6 +263,40 @@ func main() {
 		maxDepth = *flagDepth
 	}
 
+	// Determine minimum cache age for cleanup
+	minAgeStr := cfg.Options.MinAge
+	if *flagOlder != "" {
+		minAgeStr = *flagOlder
+	}
+	minAge, err := cachecore.ParseAge(minAgeStr)
+	if err != nil {
+		fmt.Println("config error:", err)
+		os.Exit(1)
+	}
+	ageLimit = minAge
+
+	// Determine git activity policy
+	policy := activityPolicy{requireClean: cfg.Options.RequireClean || *flagRequireClean}
+	minCommitAgeStr := cfg.Options.MinCommitAge
+	if *flagInactive != "" {
+		minCommitAgeStr = *flagInactive
+	}
+	policy.minCommitAge, err = cachecore.ParseAge(minCommitAgeStr)
+	if err != nil {
+		fmt.Println("config error:", err)
+		os.Exit(1)
+	}
+
+	ignoredOnly := cfg.Options.IgnoredOnly || *flagIgnoredOnly
+
+	excluder, err = cachecore.NewExcluder(cachecore.DefaultIgnorePath())
+	if err != nil {
+		fmt.Println("ignore file error:", err)
+		os.Exit(1)
+	}
+	excluder.Add("config", cfg.Options.Exclude...)
+	excluder.Add("--exclude", *flagExclude...)
+
 	// Filter languages
 	selectedLangs := map[string]bool{}
 	if *flagLangs != "" {
@@ -325,17 +344,33 @@ func main() {
 	}
 
 	rep := Report{
-		OS:       runtime.GOOS,
-		Arch:     runtime.GOARCH,
-		DryRun:   !*flagClean,
-		When:     time.Now(),
+		Envelope: cachecore.NewEnvelope(!*flagClean),
 		ScanPath: scanPath,
 		MaxDepth: maxDepth,
+		MinAge:   minAgeStr,
+
+		MinCommitAge: minCommitAgeStr,
+		RequireClean: policy.requireClean,
+		IgnoredOnly:  ignoredOnly,
+
 		Findings: []Finding{},
 		Warnings: []string{},
 	}
-	if h, _ := os.Hostname(); h != "" {
-		rep.Hostname = h
+	rep.SizeMode = newSizer().Mode()
+
+	// Record every run that gets past the scan, including --json and dry runs
+	var cleaned map[string]int64
+	var reclaimed int64
+	defer func() {
+		if !*flagNoHistory {
+			if err := newHistory().Append(historyRecord(rep, cleaned, reclaimed)); err != nil {
+				fmt.Fprintln(os.Stderr, "history error:", err)
+			}
+		}
+	}()
+
+	if *flagOneFS {
+		boundary = cachecore.NewBoundary(scanPath)
 	}
 
 	// Scan for cache directories
@@ -344,7 +379,16 @@ func main() {
 		fmt.Printf("Language detection enabled - scanning with language-specific patterns\n")
 	}
 	findings := scanDirectory(scanPath, maxDepth, allPatterns, patternToLang, cfg.Options.DetectLanguage, langSignatures, langPriorities, langToPatterns)
+	markEligibility(findings, minAge, rep.When)
+	if ignoredOnly {
+		applyIgnorePolicy(findings)
+	}
+	applyActivityPolicy(findings, policy, rep.When)
 	rep.Findings = findings
+	rep.Excluded = excluder.Excluded()
+	for _, m := range boundary.Skipped() {
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("skipped %s: on another filesystem (use --one-file-system=false to scan it)", m))
+	}
 
 	var total int64
 	for _, f := range findings {
@@ -365,7 +409,11 @@ func main() {
 
 	fmt.Printf("Config: %s\n", *flagConfig)
 	fmt.Printf("Scan: %s\n", rep.When.Format(time.RFC3339))
-	fmt.Printf("Dry-run: %v\n\n", rep.DryRun)
+	fmt.Printf("Dry-run: %v\n", rep.DryRun)
+	if summary := cachecore.ExcludedSummary(rep.Excluded); summary != "" {
+		fmt.Println(summary)
+	}
+	fmt.Println()
 
 	if len(findings) == 0 {
 		fmt.Println("No cache directories found.")
@@ -377,15 +425,26 @@ func main() {
 
 	// Cleanup if requested
 	if *flagClean {
-		cacheFindings, cacheTotal := filterCacheFindings(findings)
+		allCacheFindings, _ := filterCacheFindings(findings)
+		cacheFindings, skipped := splitEligible(allCacheFindings)
 
+		if len(skipped) > 0 {
+			fmt.Printf("\nSkipping %d cache directories:\n", len(skipped))
+			for _, f := range skipped {
+				fmt.Printf("  - %s (%s)\n", f.Path, f.Reason)
+			}
+		}
 		if len(cacheFindings) == 0 {
 			fmt.Println("\nNo cache directories found to delete.")
 			return
 		}
 
 		if !*flagYes {
-			fmt.Printf("\nWARNING: This will delete %d cache directories:\n", len(cacheFindings))
+			if *flagQuarantine {
+				fmt.Printf("\nThis will move %d cache directories to quarantine:\n", len(cacheFindings))
+			} else {
+				fmt.Printf("\nWARNING: This will delete %d cache directories:\n", len(cacheFindings))
+			}
 			// Sort findings by size (largest first) for display
 			sortedFindings := make([]Finding, len(cacheFindings))
 			copy(sortedFindings, cacheFindings)
@@ -393,7 +452,7 @@ func main() {
 				return sortedFindings[i].SizeBytes > sortedFindings[j].SizeBytes
 			})
 			for _, f := range sortedFindings {
-				fmt.Printf("  - %s (%s)\n", f.Path, human(f.SizeBytes))
+				fmt.Printf("  - %s (%s, %s)\n", f.Path, human(f.SizeBytes), f.Reason)
 			}
 			fmt.Printf("\nContinue? [y/N]: ")
 			reader := bufio.NewReader(os.Stdin)
@@ -409,29 +468,56 @@ func main() {
 			}
 		}
 
-		fmt.Println("\nDeleting cache directories...")
-		beforeTotal := cacheTotal
+		var run *cachecore.QuarantineRun
+		if *flagQuarantine {
+			fmt.Println("\nMoving cache directories to quarantine...")
+			run, err = newQuarantine().Begin(time.Now())
+			if err != nil {
+				fmt.Println("quarantine error:", err)
+				os.Exit(1)
+			}
+		} else {
+			fmt.Println("\nDeleting cache directories...")
+		}
 		var deletedCount int
 		var errors []string
+		cleaned = map[string]int64{}
 
 		for _, f := range cacheFindings {
-			if err := os.RemoveAll(f.Path); err != nil {
+			var err error
+			if run != nil {
+				err = run.Hold(f.Path, f.SizeBytes)
+			} else {
+				err = os.RemoveAll(f.Path)
+			}
+			if err != nil {
 				errors = append(errors, fmt.Sprintf("%s: %v", f.Path, err))
 				continue
 			}
+			cleaned[f.Path] = 0
 			deletedCount++
 		}
 
-		// Re-scan to verify
-		fmt.Println("Re-scanning after cleanup...")
-		afterFindings := scanDirectory(scanPath, maxDepth, allPatterns, patternToLang, cfg.Options.DetectLanguage, langSignatures, langPriorities, langToPatterns)
-		afterTotal := totalCacheBytes(afterFindings)
-		freed := bytesFreed(beforeTotal, afterTotal)
-		fmt.Printf("\nDeleted %d directories", deletedCount)
-		if freed > 0 {
-			fmt.Printf(", freed %s", human(freed))
+		if run != nil {
+			// Nothing is freed until the run is purged, so there is nothing to re-scan for
+			if err := run.Close(); err != nil {
+				errors = append(errors, fmt.Sprintf("quarantine: %v", err))
+			}
+			fmt.Printf("\nQuarantined %d directories (%s) as run %s\n", deletedCount, human(run.TotalBytes()), run.ID)
+			if deletedCount > 0 {
+				fmt.Println("Undo with 'dev-cache restore'; free the space with 'dev-cache purge --older-than 7d'")
+			}
+		} else {
+			// Re-inspect only the directories we tried to delete
+			fmt.Println("Verifying deleted directories...")
+			freed := verifyRemoved(cacheFindings)
+			reclaimed = freed
+			fmt.Printf("\nDeleted %d directories", deletedCount)
+			if freed > 0 {
+				fmt.Printf(", freed %s", human(freed))
+			}
+			fmt.Println()
 		}
-		fmt.Println()
 
 		if len(errors) > 0 {
 			fmt.Println("\nErrors:")
@@ -449,6 +535,250 @@ func main() {
 	}
 }
 
+// runRestore implements "dev-cache restore": it moves the directories of a quarantine
+// run (the latest by default) back to where they were.
+func runRestore(args []string) int {
+	set := flag.NewFlagSet("restore", flag.ExitOnError)
+	runID := set.String("run", "", "Quarantine run to restore (default: latest)")
+	list := set.Bool("list", false, "List quarantine runs and exit")
+	_ = set.Parse(args)
+
+	runs, err := newQuarantine().Runs()
+	if err != nil {
+		fmt.Println("quarantine error:", err)
+		return 1
+	}
+	if len(runs) == 0 {
+		fmt.Println("Quarantine is empty.")
+		return 0
+	}
+
+	if *list {
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header("Run", "When", "Directories", "Size")
+		for _, r := range runs {
+			if err := table.Append(r.ID, r.When.Format(time.RFC3339), fmt.Sprintf("%d", len(r.Entries)), human(r.TotalBytes())); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to append table row: %v\n", err)
+			}
+		}
+		if err := table.Render(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+		}
+		return 0
+	}
+
+	run := runs[len(runs)-1]
+	if *runID != "" {
+		run = nil
+		for _, r := range runs {
+			if r.ID == *runID {
+				run = r
+			}
+		}
+		if run == nil {
+			fmt.Printf("No quarantine run %q (see 'dev-cache restore --list')\n", *runID)
+			return 1
+		}
+	}
+
+	total := len(run.Entries)
+	errs := run.Restore()
+	fmt.Printf("Restored %d of %d directories from run %s\n", total-len(run.Entries), total, run.ID)
+	if len(errs) > 0 {
+		fmt.Println("\nErrors:")
+		for _, e := range errs {
+			fmt.Printf("  - %v\n", e)
+		}
+		return 1
+	}
+	return 0
+}
+
+// runPurge implements "dev-cache purge": it permanently deletes quarantine runs older
+// than --older-than, which is when the disk space is actually reclaimed.
+func runPurge(args []string) int {
+	set := flag.NewFlagSet("purge", flag.ExitOnError)
+	olderThan := set.String("older-than", "7d", "Only purge runs quarantined longer ago than this (0 = all)")
+	_ = set.Parse(args)
+
+	minAge, err := cachecore.ParseAge(*olderThan)
+	if err != nil {
+		fmt.Println("purge error:", err)
+		return 1
+	}
+	runs, err := newQuarantine().Runs()
+	if err != nil {
+		fmt.Println("quarantine error:", err)
+		return 1
+	}
+
+	now := time.Now()
+	var purged int
+	var freed int64
+	var errs []string
+	for _, r := range runs {
+		if now.Sub(r.When) < minAge {
+			continue
+		}
+		// A failed purge keeps only the entries it could not delete in the run
+		held := r.TotalBytes()
+		err := r.Purge()
+		freed += held - r.TotalBytes()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", r.ID, err))
+			continue
+		}
+		purged++
+	}
+
+	fmt.Printf("Purged %d of %d quarantine runs, freed %s\n", purged, len(runs), human(freed))
+	if freed > 0 {
+		// Quarantined space is only reclaimed now, so the purge counts as a run of its own
+		if err := newHistory().Append(cachecore.HistoryRecord{When: now, Reclaimed: freed}); err != nil {
+			fmt.Fprintln(os.Stderr, "history error:", err)
+		}
+	}
+	if len(errs) > 0 {
+		fmt.Println("\nErrors:")
+		for _, e := range errs {
+			fmt.Printf("  - %s\n", e)
+		}
+		return 1
+	}
+	return 0
+}
+
+// historyDims are the dimensions dev-cache records sizes for in the scan history.
+var historyDims = []string{"language", "project"}
+
+// newHistory returns the scan history file under ~/.local/state/dev-cache.
+func newHistory() cachecore.History { return cachecore.DefaultHistory("dev-cache") }
+
+// historyRecord summarises a run for the scan history: cache sizes per language, per
+// project and per directory, plus what was left of each cleaned directory.
+func historyRecord(rep Report, cleaned map[string]int64, reclaimed int64) cachecore.HistoryRecord {
+	rec := cachecore.HistoryRecord{
+		When:      rep.When,
+		DryRun:    rep.DryRun,
+		Reclaimed: reclaimed,
+		Groups:    map[string]map[string]int64{"language": {}, "project": {}},
+		Paths:     map[string]int64{},
+		Cleaned:   cleaned,
+	}
+	for _, f := range rep.Findings {
+		if !isCacheDirectory(f) || f.Err != "" {
+			continue
+		}
+		project := f.ProjectRoot
+		if project == "" {
+			project = filepath.Dir(f.Path)
+		}
+		lang := f.Language
+		if lang == "" {
+			lang = "unknown"
+		}
+		rec.Total += f.SizeBytes
+		rec.Groups["language"][lang] += f.SizeBytes
+		rec.Groups["project"][project] += f.SizeBytes
+		rec.Paths[f.Path] = f.SizeBytes
+	}
+	return rec
+}
+
+// runHistory implements "dev-cache history": recent runs with the bytes they reclaimed,
+// growth per language and per project, and the caches that regrow fastest after cleaning.
+func runHistory(args []string) int {
+	set := flag.NewFlagSet("history", flag.ExitOnError)
+	limit := set.Int("limit", 10, "Number of rows to show per table (0 = all)")
+	asJSON := set.Bool("json", false, "Output the history summary as JSON")
+	_ = set.Parse(args)
+
+	h := newHistory()
+	recs, err := h.Load()
+	if err != nil {
+		fmt.Println("history error:", err)
+		return 1
+	}
+	sum := cachecore.Summarize(recs, historyDims)
+
+	if *asJSON {
+		b, err := json.MarshalIndent(sum, "", "  ")
+		if err != nil {
+			fmt.Println("json error:", err)
+			return 1
+		}
+		fmt.Println(string(b))
+		return 0
+	}
+	if len(recs) == 0 {
+		fmt.Printf("No history recorded yet in %s.\n", h.Path)
+		return 0
+	}
+	shown := func(i int) bool { return *limit == 0 || i < *limit }
+
+	fmt.Printf("Runs (%d recorded, latest first):\n\n", len(recs))
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("When", "Mode", "Caches", "Reclaimed")
+	var reclaimed int64
+	for i := range recs {
+		rec := recs[len(recs)-1-i]
+		reclaimed += rec.Reclaimed
+		if !shown(i) {
+			continue
+		}
+		mode := "clean"
+		if rec.DryRun {
+			mode = "scan"
+		}
+		if err := table.Append(rec.When.Format(time.RFC3339), mode, human(rec.Total), human(rec.Reclaimed)); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to append table row: %v\n", err)
+		}
+	}
+	table.Footer("ALL RUNS", "", "", human(reclaimed))
+	if err := table.Render(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+	}
+
+	for _, dim := range historyDims {
+		rows := sum.Growth[dim]
+		if len(rows) == 0 {
+			continue
+		}
+		fmt.Printf("\nGrowth per %s:\n\n", dim)
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(dim, "Since", "First", "Latest", "Change", "Per Day")
+		for i, g := range rows {
+			if !shown(i) {
+				break
+			}
+			if err := table.Append(g.Key, g.Since.Format("2006-01-02"), human(g.First), human(g.Last), cachecore.SignedHuman(g.Last-g.First), cachecore.SignedHuman(int64(g.PerDay))); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to append table row: %v\n", err)
+			}
+		}
+		if err := table.Render(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+		}
+	}
+
+	if len(sum.Regrowth) > 0 {
+		fmt.Printf("\nFastest regrowing caches:\n\n")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header("Path", "Cleaned", "Size Now", "Per Day")
+		for i, g := range sum.Regrowth {
+			if !shown(i) {
+				break
+			}
+			if err := table.Append(g.Path, g.CleanedAt.Format("2006-01-02"), human(g.Size), cachecore.SignedHuman(int64(g.PerDay))); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to append table row: %v\n", err)
+			}
+		}
+		if err := table.Render(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+		}
+	}
+	return 0
+}
+
 // filterCacheFindings returns only findings representing cache directories and the total bytes they consume.
 func filterCacheFindings(findings []Finding) ([]Finding, int64) {
 	var cacheFindings []Finding
@@ -462,6 +792,220 @@ func filterCacheFindings(findings []Finding) ([]Finding, int64) {
 	return cacheFindings, total
 }
 
+// markEligibility records on every cache directory whether --clean may delete it and why.
+// With a zero minAge every cache directory qualifies; otherwise the newest file inside it
+// must be at least minAge old, so caches of projects being worked on are kept.
+func markEligibility(findings []Finding, minAge time.Duration, now time.Time) {
+	for i := range findings {
+		f := &findings[i]
+		if !isCacheDirectory(*f) {
+			continue
+		}
+		age := now.Sub(f.ModMax)
+		if !f.ModMax.IsZero() {
+			f.AgeDays = int(age / (24 * time.Hour))
+		}
+		switch {
+		case minAge == 0:
+			f.Eligible = true
+			f.Reason = fmt.Sprintf("matches %s", f.Pattern)
+		case f.ModMax.IsZero():
+			f.Eligible = true
+			f.Reason = "empty directory"
+		case age >= minAge:
+			f.Eligible = true
+			f.Reason = fmt.Sprintf("untouched for %s, older than %s", cachecore.HumanAge(age), cachecore.HumanAge(minAge))
+		default:
+			f.Eligible = false
+			f.Reason = fmt.Sprintf("modified %s ago, newer than %s", cachecore.HumanAge(age), cachecore.HumanAge(minAge))
+		}
+	}
+}
+
+// applyIgnorePolicy asks git how it treats every cache directory and only leaves those
+// ignored by the project's .gitignore rules eligible. Directories holding tracked files
+// are marked protected, since deleting them would change the repository. The work tree
+// is resolved once per project root and each repository is queried with one batch.
+func applyIgnorePolicy(findings []Finding) {
+	type repo struct {
+		top string
+		err error
+	}
+	repos := map[string]repo{}  // Project root -> work tree
+	byTop := map[string][]int{} // Work tree top level -> finding indexes
+	var tops []string
+	for i := range findings {
+		f := &findings[i]
+		if !isCacheDirectory(*f) {
+			continue
+		}
+		root := f.ProjectRoot
+		if root == "" {
+			root = filepath.Dir(f.Path)
+		}
+		r, ok := repos[root]
+		if !ok {
+			r.top, r.err = cachecore.GitTopLevel(root)
+			repos[root] = r
+		}
+		if r.err != nil {
+			setIgnoreStatus(f, "", r.err)
+			continue
+		}
+		if byTop[r.top] == nil {
+			tops = append(tops, r.top)
+		}
+		byTop[r.top] = append(byTop[r.top], i)
+	}
+
+	for _, top := range tops {
+		idx := byTop[top]
+		paths := make([]string, len(idx))
+		for n, i := range idx {
+			paths[n] = findings[i].Path
+		}
+		statuses, err := cachecore.GitPathStatuses(top, paths)
+		for _, i := range idx {
+			status, ok := statuses[findings[i].Path]
+			pathErr := err
+			if pathErr == nil && !ok {
+				pathErr = fmt.Errorf("outside git work tree %s", top)
+			}
+			setIgnoreStatus(&findings[i], status, pathErr)
+		}
+	}
+}
+
+// setIgnoreStatus records how git treats a cache directory and updates its eligibility.
+func setIgnoreStatus(f *Finding, status string, err error) {
+	f.VCS = status
+	switch {
+	case err != nil:
+		f.Eligible = false
+		f.Reason = err.Error()
+	case status == cachecore.PathTracked:
+		f.Protected = true
+		f.Eligible = false
+		f.Reason = "protected: tracked by git"
+	case status == cachecore.PathUntracked && f.Eligible:
+		f.Eligible = false
+		f.Reason = "not ignored by .gitignore"
+	case status == cachecore.PathIgnored && f.Eligible:
+		f.Reason += "; ignored by git"
+	}
+}
+
+// activityPolicy decides from a project's git state whether its caches may be cleaned.
+type activityPolicy struct {
+	minCommitAge time.Duration // Last commit must be at least this old; 0 = ignore commit dates
+	requireClean bool          // No uncommitted changes, untracked files or stashes
+}
+
+func (p activityPolicy) active() bool { return p.minCommitAge > 0 || p.requireClean }
+
+// check reports whether a project with git state g passes the policy and why.
+func (p activityPolicy) check(g cachecore.GitActivity, now time.Time) (bool, string) {
+	var notes []string
+	if p.minCommitAge > 0 {
+		if g.LastCommit.IsZero() {
+			return false, "no commits yet"
+		}
+		age := now.Sub(g.LastCommit)
+		if age < p.minCommitAge {
+			return false, fmt.Sprintf("last commit %s ago, newer than %s", cachecore.HumanAge(age), cachecore.HumanAge(p.minCommitAge))
+		}
+		notes = append(notes, fmt.Sprintf("last commit %s ago", cachecore.HumanAge(age)))
+	}
+	if p.requireClean {
+		switch {
+		case g.Changed > 0:
+			return false, fmt.Sprintf("%d uncommitted changes", g.Changed)
+		case g.Untracked > 0:
+			return false, fmt.Sprintf("%d untracked files", g.Untracked)
+		case g.Stashes > 0:
+			return false, fmt.Sprintf("%d stashes", g.Stashes)
+		}
+		notes = append(notes, "clean worktree")
+	}
+	return true, strings.Join(notes, ", ")
+}
+
+// applyActivityPolicy reads the git state of each project that still has eligible caches
+// and withdraws eligibility when the project looks active. Projects outside a git work
+// tree cannot be judged and are kept. Git state is read once per project root.
+func applyActivityPolicy(findings []Finding, p activityPolicy, now time.Time) {
+	if !p.active() {
+		return
+	}
+	type state struct {
+		git *cachecore.GitActivity
+		err error
+	}
+	seen := map[string]state{}
+	for i := range findings {
+		f := &findings[i]
+		if !f.Eligible {
+			continue
+		}
+		root := f.ProjectRoot
+		if root == "" {
+			root = filepath.Dir(f.Path)
+		}
+		st, ok := seen[root]
+		if !ok {
+			g, err := cachecore.ReadGitActivity(root)
+			if err == nil {
+				st.git = &g
+			}
+			st.err = err
+			seen[root] = st
+		}
+		if st.err != nil {
+			f.Eligible = false
+			f.Reason = st.err.Error()
+			continue
+		}
+		f.Git = st.git
+		ok, why := p.check(*st.git, now)
+		if !ok {
+			f.Eligible = false
+			f.Reason = why
+			continue
+		}
+		f.Reason += "; " + why
+	}
+}
+
+// splitEligible separates cache findings into those --clean may delete and those it must keep.
+func splitEligible(findings []Finding) (eligible, skipped []Finding) {
+	for _, f := range findings {
+		if f.Eligible {
+			eligible = append(eligible, f)
+		} else {
+			skipped = append(skipped, f)
+		}
+	}
+	return eligible, skipped
+}
+
+// verifyRemoved re-measures just the given cache directories after a cleanup and returns
+// how many bytes were freed. Directories that no longer exist count as fully freed; any
+// that were only partly deleted count for what is gone.
+func verifyRemoved(removed []Finding) int64 {
+	paths := make([]string, len(removed))
+	for i, f := range removed {
+		paths[i] = f.Path
+	}
+	var left int64
+	after, errs := newSizer().InspectAll(paths)
+	for i, f := range after {
+		if errs[i] == nil {
+			left += f.SizeBytes
+		}
+	}
+	return bytesFreed(totalCacheBytes(removed), left)
+}
+
 // totalCacheBytes calculates the total bytes consumed by cache directories within the findings slice.
 func totalCacheBytes(findings []Finding) int64 {
 	var total int64
@@ -568,6 +1112,8 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 	findingsByPath := make(map[string]bool)
 	// Track excluded directories (will be reported with empty language)
 	excludedDirs := make(map[string]bool)
+	// Indexes into findings of matched cache directories still to be sized
+	var toSize []int
 
 	if err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -578,6 +1124,11 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 			return nil
 		}
 
+		// Never descend into directories held by --quarantine
+		if strings.HasPrefix(d.Name(), cachecore.QuarantineDirPrefix) {
+			return filepath.SkipDir
+		}
+
 		// Normalize and get absolute path for comparison
 		cleanPath := filepath.Clean(path)
 		pathAbs, err := filepath.Abs(cleanPath)
@@ -590,6 +1141,11 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 			return nil
 		}
 
+		// Skip excluded directories and other filesystems before looking inside them
+		if excluder.Skip(cleanPath, true) || boundary.Crosses(cleanPath, d) {
+			return filepath.SkipDir
+		}
+
 		// Calculate current depth relative to root
 		// Count separators in the relative path from root
 		relPath, err := filepath.Rel(root, cleanPath)
@@ -715,13 +1271,10 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 					// Don't report for subdirectories like .git, docs, etc. that are not project roots
 					if depth == 0 {
 						f := Finding{
-							Path:        path,
+							Finding:     cachecore.Finding{Path: path},
 							ProjectRoot: cleanPath, // Same as Path since this is the project root
 							Language:    getLanguageForExclusion("", excludedDirs[cleanPath]),
 							Pattern:     "",
-							SizeBytes:   0,
-							Items:       0,
-							ModMax:      time.Time{},
 						}
 						findings = append(findings, f)
 						// Remove from tracking since we've reported it
@@ -743,13 +1296,10 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 					// Check if project root still doesn't have a language
 					if cachedLang, ok := langCache[projectRoot]; !ok || cachedLang == "" {
 						f := Finding{
-							Path:        projectRoot,
+							Finding:     cachecore.Finding{Path: projectRoot},
 							ProjectRoot: projectRoot, // Same as Path since this is the project root
 							Language:    getLanguageForExclusion("", excludedDirs[projectRoot]),
 							Pattern:     "",
-							SizeBytes:   0,
-							Items:       0,
-							ModMax:      time.Time{},
 						}
 						findings = append(findings, f)
 						// Remove from tracking since we've reported it
@@ -770,11 +1320,8 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 		// This ensures cache directories are detected even if they're at depth 0
 		for _, pattern := range patternsToCheck {
 			if matchPattern(dirName, pattern) {
-				// Found a match - calculate size
-				f, err := inspectPath(path)
-				if err != nil {
-					f.Err = err.Error()
-				}
+				// Found a match - record it now and size it once the walk is done
+				f := Finding{Finding: cachecore.Finding{Path: path}}
 				f.Pattern = pattern
 				// Use detected language when available; patternToLang can be wrong when
 				// multiple languages share a pattern (e.g. node_modules in node, nextjs, vue)
@@ -791,6 +1338,7 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 					f.ProjectRoot = filepath.Dir(cleanPath)
 				}
 				findings = append(findings, f)
+				toSize = append(toSize, len(findings)-1)
 				// Track this path in the map for O(1) lookups
 				findingsByPath[cleanPath] = true
 
@@ -809,13 +1357,10 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 				// Check if we need to report this depth 0 directory
 				if _, needsReport := depth0NoLang[projectRoot]; needsReport {
 					f := Finding{
-						Path:        projectRoot,
+						Finding:     cachecore.Finding{Path: projectRoot},
 						ProjectRoot: projectRoot,
 						Language:    "no language found",
 						Pattern:     "",
-						SizeBytes:   0,
-						Items:       0,
-						ModMax:      time.Time{},
 					}
 					findings = append(findings, f)
 					delete(depth0NoLang, projectRoot)
@@ -829,6 +1374,9 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 		fmt.Fprintf(os.Stderr, "warning: directory walk error: %v\n", err)
 	}
 
+	// Size matched directories concurrently instead of one by one inside the walk
+	sizeFindings(findings, toSize)
+
 	// After scanning, report all depth 0 directories that were scanned but don't have cache directory findings
 	// This ensures directories with detected languages are always reported, even if they don't have cache directories
 	// Track which depth 0 directories have findings (cache directories found)
@@ -851,13 +1399,10 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 			// Always report the language if found, even if there are no cache directories
 			detectedLang := langCache[projectRoot]
 			f := Finding{
-				Path:        projectRoot,
+				Finding:     cachecore.Finding{Path: projectRoot},
 				ProjectRoot: projectRoot, // Same as Path since this is the project root
 				Language:    getLanguageForExclusion(detectedLang, excludedDirs[projectRoot]),
 				Pattern:     "",
-				SizeBytes:   0,
-				Items:       0,
-				ModMax:      time.Time{},
 			}
 			findings = append(findings, f)
 		}
@@ -866,6 +1411,22 @@ func scanDirectory(root string, maxDepth int, patterns []string, patternToLang m
 	return findings
 }
 
+// sizeFindings measures findings[i] for every i in idx using up to --jobs concurrent walkers.
+// Pattern, language and project root are kept; size, items, mtime and error are filled in.
+func sizeFindings(findings []Finding, idx []int) {
+	paths := make([]string, len(idx))
+	for n, i := range idx {
+		paths[n] = findings[i].Path
+	}
+	sized, errs := newSizer().InspectAll(paths)
+	for n, i := range idx {
+		findings[i].Finding = sized[n]
+		if errs[n] != nil {
+			findings[i].Err = errs[n].Error()
+		}
+	}
+}
+
 // matchPattern checks if a directory name matches a pattern
 // Supports exact match and simple wildcard patterns
 func matchPattern(name, pattern string) bool {
@@ -889,7 +1450,9 @@ func displayDetailed(findings []Finding, total int64) {
 		SizeBytes int64
 		Items     int
 		Language  string
-		Patterns  []string // Track all patterns of this type
+		Patterns  []string  // Track all patterns of this type
+		Newest    time.Time // Most recent file modification across caches of this type
+		Protected bool      // At least one cache of this type is tracked by git
 	}
 
 	// First pass: identify which projects have actual cache directories
@@ -945,6 +1508,12 @@ func displayDetailed(findings []Finding, total int64) {
 
 		projectGroups[projectPath][cacheType].SizeBytes += f.SizeBytes
 		projectGroups[projectPath][cacheType].Items += f.Items
+		if f.Protected {
+			projectGroups[projectPath][cacheType].Protected = true
+		}
+		if f.ModMax.After(projectGroups[projectPath][cacheType].Newest) {
+			projectGroups[projectPath][cacheType].Newest = f.ModMax
+		}
 		if isCacheDirectory(f) && !contains(projectGroups[projectPath][cacheType].Patterns, f.Pattern) {
 			projectGroups[projectPath][cacheType].Patterns = append(projectGroups[projectPath][cacheType].Patterns, f.Pattern)
 		}
@@ -957,6 +1526,7 @@ func displayDetailed(findings []Finding, total int64) {
 		totalItems int
 		cacheTypes map[string]*cacheTypeSummary
 		language   string
+		newest     time.Time
 	}
 
 	var projectEntries []projectEntry
@@ -964,11 +1534,15 @@ func displayDetailed(findings []Finding, total int64) {
 		var projectTotalSize int64
 		var projectTotalItems int
 		var detectedLanguage string
+		var newest time.Time
 
 		// Calculate totals and find language
 		for _, summary := range cacheTypes {
 			projectTotalSize += summary.SizeBytes
 			projectTotalItems += summary.Items
+			if summary.Newest.After(newest) {
+				newest = summary.Newest
+			}
 			if detectedLanguage == "" && summary.Language != "" {
 				detectedLanguage = summary.Language
 			}
@@ -980,6 +1554,7 @@ func displayDetailed(findings []Finding, total int64) {
 			totalItems: projectTotalItems,
 			cacheTypes: cacheTypes,
 			language:   detectedLanguage,
+			newest:     newest,
 		})
 	}
 
@@ -990,7 +1565,7 @@ func displayDetailed(findings []Finding, total int64) {
 
 	// Display grouped results - one line per project with all cache types listed
 	table := tablewriter.NewWriter(os.Stdout)
-	table.Header("Project Path", "Cache Types", "Language", "Total Size", "Total Items")
+	table.Header("Project Path", "Cache Types", "Language", "Age", "Total Size", "Total Items")
 
 	var totalItems int
 	for _, project := range projectEntries {
@@ -1015,21 +1590,31 @@ func displayDetailed(findings []Finding, total int64) {
 		// Build cache types list string
 		var cacheTypeList []string
 		for _, entry := range sortedCacheTypes {
-			cacheTypeList = append(cacheTypeList, entry.name)
+			name := entry.name
+			if entry.summary.Protected {
+				name += " (protected)"
+			}
+			cacheTypeList = append(cacheTypeList, name)
 		}
 
 		// Join cache types with semicolons for readability
 		cacheTypesStr := strings.Join(cacheTypeList, "; ")
 
+		// Age of the most recently modified cache file in this project
+		age := "-"
+		if !project.newest.IsZero() {
+			age = cachecore.HumanAge(time.Since(project.newest))
+		}
+
 		// Add single row for this project
-		if err := table.Append(project.path, cacheTypesStr, project.language, human(project.totalSize), fmt.Sprintf("%d", project.totalItems)); err != nil {
+		if err := table.Append(project.path, cacheTypesStr, project.language, age, human(project.totalSize), fmt.Sprintf("%d", project.totalItems)); err != nil {
 			fmt.Fprintf(os.Stderr, "warning: failed to append table row: %v\n", err)
 		}
 
 		totalItems += project.totalItems
 	}
 
-	table.Footer("TOTAL", "", "", human(total), fmt.Sprintf("%d", totalItems))
+	table.Footer("TOTAL", "", "", "", human(total), fmt.Sprintf("%d", totalItems))
 	if err := table.Render(); err != nil {
 		fmt.Fprintf(os.Stderr, "warning: failed to render table: %v\n", err)
 	}
@@ -3,9 +3,14 @@ package main
 import (
 	"bytes"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
+
+	"cachecore"
 )
 
 func TestCheckVersionFlag(t *testing.T) {
@@ -452,8 +457,8 @@ func TestDisplayDetailed(t *testing.T) {
 	defer func() { os.Stdout = old }()
 
 	findings := []Finding{
-		{Path: "/proj1/node_modules", ProjectRoot: "/proj1", SizeBytes: 1000, Items: 5, Pattern: "node_modules", Language: "node"},
-		{Path: "/proj1/.venv", ProjectRoot: "/proj1", SizeBytes: 500, Items: 2, Pattern: ".venv", Language: "python"},
+		{Finding: cachecore.Finding{Path: "/proj1/node_modules", SizeBytes: 1000, Items: 5}, ProjectRoot: "/proj1", Pattern: "node_modules", Language: "node"},
+		{Finding: cachecore.Finding{Path: "/proj1/.venv", SizeBytes: 500, Items: 2}, ProjectRoot: "/proj1", Pattern: ".venv", Language: "python"},
 	}
 	displayDetailed(findings, 1500)
 
@@ -474,7 +479,7 @@ func TestDisplayDetailedNoCache(t *testing.T) {
 
 	// Project with no cache directories (Pattern empty)
 	findings := []Finding{
-		{Path: "/proj2", ProjectRoot: "/proj2", SizeBytes: 0, Items: 0, Pattern: "", Language: "no language found"},
+		{Finding: cachecore.Finding{Path: "/proj2", SizeBytes: 0, Items: 0}, ProjectRoot: "/proj2", Pattern: "", Language: "no language found"},
 	}
 	displayDetailed(findings, 0)
 	_ = w.Close()
@@ -493,8 +498,8 @@ func TestDisplayDetailedWithError(t *testing.T) {
 
 	// Finding with error should be skipped in grouping but table still renders
 	findings := []Finding{
-		{Path: "/proj1/node_modules", ProjectRoot: "/proj1", SizeBytes: 1000, Items: 5, Pattern: "node_modules", Language: "node"},
-		{Path: "/proj1/bad", ProjectRoot: "/proj1", SizeBytes: 0, Items: 0, Pattern: "node_modules", Language: "node", Err: "permission denied"},
+		{Finding: cachecore.Finding{Path: "/proj1/node_modules", SizeBytes: 1000, Items: 5}, ProjectRoot: "/proj1", Pattern: "node_modules", Language: "node"},
+		{Finding: cachecore.Finding{Path: "/proj1/bad", SizeBytes: 0, Items: 0, Err: "permission denied"}, ProjectRoot: "/proj1", Pattern: "node_modules", Language: "node"},
 	}
 	displayDetailed(findings, 1000)
 	_ = w.Close()
@@ -646,9 +651,9 @@ func TestScanDirectoryWithExcludedDir(t *testing.T) {
 
 func TestFilterCacheFindings(t *testing.T) {
 	findings := []Finding{
-		{Path: "/proj/node_modules", Pattern: "node_modules", SizeBytes: 100},
-		{Path: "/proj/.venv", Pattern: ".venv", SizeBytes: 200},
-		{Path: "/proj/README.md", Pattern: "", SizeBytes: 10},
+		{Finding: cachecore.Finding{Path: "/proj/node_modules", SizeBytes: 100}, Pattern: "node_modules"},
+		{Finding: cachecore.Finding{Path: "/proj/.venv", SizeBytes: 200}, Pattern: ".venv"},
+		{Finding: cachecore.Finding{Path: "/proj/README.md", SizeBytes: 10}, Pattern: ""},
 	}
 	cacheOnly, total := filterCacheFindings(findings)
 	if len(cacheOnly) != 2 {
@@ -666,9 +671,9 @@ func TestFilterCacheFindings(t *testing.T) {
 
 func TestTotalCacheBytes(t *testing.T) {
 	findings := []Finding{
-		{Pattern: "node_modules", SizeBytes: 50},
-		{Pattern: "", SizeBytes: 25},
-		{Pattern: ".venv", SizeBytes: 75},
+		{Finding: cachecore.Finding{SizeBytes: 50}, Pattern: "node_modules"},
+		{Finding: cachecore.Finding{SizeBytes: 25}, Pattern: ""},
+		{Finding: cachecore.Finding{SizeBytes: 75}, Pattern: ".venv"},
 	}
 	if got := totalCacheBytes(findings); got != 125 {
 		t.Fatalf("expected 125 cache bytes, got %d", got)
@@ -702,3 +707,377 @@ func TestScanDirectoryWalkError(t *testing.T) {
 	// Should not panic; may or may not find things depending on walk behavior
 	_ = findings
 }
+
+func TestScanDirectorySizesMatches(t *testing.T) {
+	root := t.TempDir()
+	sizes := map[string]int{"a": 100, "b": 2000, "c": 30}
+	for name, size := range sizes {
+		dir := filepath.Join(root, name, "node_modules")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "pkg.js"), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	findings := scanDirectory(root, 1, []string{"node_modules"}, map[string]string{"node_modules": "node"}, false, nil, nil, nil)
+	if len(findings) != 3 {
+		t.Fatalf("expected 3 findings, got %d", len(findings))
+	}
+	for _, f := range findings {
+		want := int64(sizes[filepath.Base(f.ProjectRoot)])
+		if f.SizeBytes != want || f.Items != 1 || f.Pattern != "node_modules" || f.ModMax.IsZero() {
+			t.Fatalf("unexpected finding for %s: %+v", f.Path, f)
+		}
+	}
+}
+
+func TestScanDirectorySkipsQuarantineHolders(t *testing.T) {
+	root := t.TempDir()
+	held := filepath.Join(root, "a", cachecore.QuarantineDirPrefix+"20250601-120000", "node_modules")
+	if err := os.MkdirAll(held, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	findings := scanDirectory(root, 2, []string{"node_modules"}, map[string]string{"node_modules": "node"}, false, nil, nil, nil)
+	for _, f := range findings {
+		if strings.Contains(f.Path, cachecore.QuarantineDirPrefix) {
+			t.Fatalf("expected quarantined directory to be skipped, got %s", f.Path)
+		}
+	}
+}
+
+func TestScanDirectoryHonoursExclusions(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a/node_modules", "Dropbox/b/node_modules", "c/node_modules", "c/keep/node_modules"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "c", cachecore.IgnoreFileName), []byte("/keep/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := excluder
+	defer func() { excluder = old }()
+	excluder, _ = cachecore.NewExcluder("")
+	excluder.Add("--exclude", "Dropbox")
+
+	findings := scanDirectory(root, 3, []string{"node_modules"}, map[string]string{"node_modules": "node"}, false, nil, nil, nil)
+	var found []string
+	for _, f := range findings {
+		if isCacheDirectory(f) {
+			rel, _ := filepath.Rel(root, f.Path)
+			found = append(found, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(found)
+	if strings.Join(found, ",") != "a/node_modules,c/node_modules" {
+		t.Fatalf("unexpected cache directories: %v", found)
+	}
+	if excluded := excluder.Excluded(); len(excluded) != 2 {
+		t.Fatalf("expected Dropbox and c/keep to be excluded, got %+v", excluded)
+	}
+}
+
+func TestScanDirectorySizeHonoursExclusions(t *testing.T) {
+	root := t.TempDir()
+	cache := filepath.Join(root, "a", "node_modules")
+	for dir, size := range map[string]int{cache: 100, filepath.Join(cache, "keep"): 2000} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "pkg.js"), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := excluder
+	defer func() { excluder = old }()
+	excluder, _ = cachecore.NewExcluder("")
+	excluder.Add("--exclude", "keep")
+
+	findings := scanDirectory(root, 1, []string{"node_modules"}, map[string]string{"node_modules": "node"}, false, nil, nil, nil)
+	for _, f := range findings {
+		if f.Path == cache && (f.SizeBytes != 100 || f.Items != 1 || f.Excluded != 1) {
+			t.Fatalf("expected the excluded subdirectory not to be counted, got %+v", f)
+		}
+	}
+}
+
+func TestMarkEligibility(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	findings := []Finding{
+		{Finding: cachecore.Finding{Path: "/old/node_modules", ModMax: now.Add(-45 * 24 * time.Hour)}, Pattern: "node_modules"},
+		{Finding: cachecore.Finding{Path: "/new/node_modules", ModMax: now.Add(-2 * 24 * time.Hour)}, Pattern: "node_modules"},
+		{Finding: cachecore.Finding{Path: "/empty/.venv"}, Pattern: ".venv"},
+		{Finding: cachecore.Finding{Path: "/proj"}, Pattern: ""},
+	}
+
+	markEligibility(findings, 30*24*time.Hour, now)
+	if !findings[0].Eligible || findings[0].AgeDays != 45 || !strings.Contains(findings[0].Reason, "45d") {
+		t.Fatalf("expected old cache to be eligible, got %+v", findings[0])
+	}
+	if findings[1].Eligible || findings[1].AgeDays != 2 || !strings.Contains(findings[1].Reason, "newer than 30d") {
+		t.Fatalf("expected recent cache to be kept, got %+v", findings[1])
+	}
+	if !findings[2].Eligible || findings[2].Reason != "empty directory" {
+		t.Fatalf("expected empty cache to be eligible, got %+v", findings[2])
+	}
+	if findings[3].Eligible || findings[3].Reason != "" {
+		t.Fatalf("expected project row to be untouched, got %+v", findings[3])
+	}
+
+	eligible, skipped := splitEligible(findings[:3])
+	if len(eligible) != 2 || len(skipped) != 1 || skipped[0].Path != "/new/node_modules" {
+		t.Fatalf("unexpected split: %d eligible, %d skipped", len(eligible), len(skipped))
+	}
+
+	// Without a minimum age every cache directory qualifies
+	markEligibility(findings, 0, now)
+	for _, f := range findings[:3] {
+		if !f.Eligible {
+			t.Fatalf("expected %s to be eligible without minAge", f.Path)
+		}
+	}
+}
+
+func TestAgeLimitBypassesIndex(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "a", "node_modules")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "pkg.js")
+	if err := os.WriteFile(file, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-60 * 24 * time.Hour)
+	for _, p := range []string{file, dir} {
+		if err := os.Chtimes(p, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldIndex, oldLimit := sizeIndex, ageLimit
+	defer func() { sizeIndex, ageLimit = oldIndex, oldLimit }()
+	sizeIndex = cachecore.LoadIndex(filepath.Join(t.TempDir(), "index.json"))
+	ageLimit = 0
+	patterns, langs := []string{"node_modules"}, map[string]string{"node_modules": "node"}
+	_ = scanDirectory(root, 1, patterns, langs, false, nil, nil, nil)
+
+	// Rewrite the file in place; the directory's mtime stays in the past
+	if err := os.WriteFile(file, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(dir, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	ageLimit = 30 * 24 * time.Hour
+	findings := scanDirectory(root, 1, patterns, langs, false, nil, nil, nil)
+	markEligibility(findings, ageLimit, time.Now())
+	for _, f := range findings {
+		if f.Path == dir && f.Eligible {
+			t.Fatalf("expected recently rewritten cache to be kept, got %+v", f)
+		}
+	}
+}
+
+func TestActivityPolicyCheck(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	p := activityPolicy{minCommitAge: 60 * 24 * time.Hour, requireClean: true}
+
+	tests := []struct {
+		name   string
+		git    cachecore.GitActivity
+		ok     bool
+		reason string
+	}{
+		{"stale and clean", cachecore.GitActivity{LastCommit: now.Add(-90 * 24 * time.Hour)}, true, "last commit 90d ago, clean worktree"},
+		{"recent commit", cachecore.GitActivity{LastCommit: now.Add(-5 * 24 * time.Hour)}, false, "last commit 5d ago, newer than 60d"},
+		{"no commits", cachecore.GitActivity{}, false, "no commits yet"},
+		{"uncommitted changes", cachecore.GitActivity{LastCommit: now.Add(-90 * 24 * time.Hour), Changed: 2}, false, "2 uncommitted changes"},
+		{"untracked files", cachecore.GitActivity{LastCommit: now.Add(-90 * 24 * time.Hour), Untracked: 1}, false, "1 untracked files"},
+		{"stashes", cachecore.GitActivity{LastCommit: now.Add(-90 * 24 * time.Hour), Stashes: 3}, false, "3 stashes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := p.check(tt.git, now)
+			if ok != tt.ok || reason != tt.reason {
+				t.Errorf("check() = %v, %q, want %v, %q", ok, reason, tt.ok, tt.reason)
+			}
+		})
+	}
+
+	if (activityPolicy{}).active() {
+		t.Error("expected zero policy to be inactive")
+	}
+}
+
+func TestApplyActivityPolicyNotRepo(t *testing.T) {
+	now := time.Now()
+	project := t.TempDir()
+	findings := []Finding{
+		{Finding: cachecore.Finding{Path: filepath.Join(project, "node_modules")}, ProjectRoot: project, Pattern: "node_modules", Eligible: true},
+		{Finding: cachecore.Finding{Path: filepath.Join(project, ".cache")}, ProjectRoot: project, Pattern: ".cache"},
+	}
+
+	applyActivityPolicy(findings, activityPolicy{}, now)
+	if !findings[0].Eligible {
+		t.Fatal("expected inactive policy to leave findings untouched")
+	}
+
+	applyActivityPolicy(findings, activityPolicy{requireClean: true}, now)
+	if findings[0].Eligible || findings[0].Reason == "" {
+		t.Fatalf("expected project outside git to be kept, got %+v", findings[0])
+	}
+	if findings[1].Reason != "" {
+		t.Fatalf("expected already skipped cache to be untouched, got %+v", findings[1])
+	}
+}
+
+func TestApplyIgnorePolicy(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	project := t.TempDir()
+	if out, err := exec.Command("git", "-C", project, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	if err := os.WriteFile(filepath.Join(project, ".gitignore"), []byte("node_modules/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"node_modules", "vendor", "build"} {
+		if err := os.MkdirAll(filepath.Join(project, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(project, "vendor", "lib.go"), []byte("package lib"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", project, "add", "vendor").CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v\n%s", err, out)
+	}
+
+	findings := []Finding{
+		{Finding: cachecore.Finding{Path: filepath.Join(project, "node_modules")}, ProjectRoot: project, Pattern: "node_modules", Eligible: true},
+		{Finding: cachecore.Finding{Path: filepath.Join(project, "vendor")}, ProjectRoot: project, Pattern: "vendor", Eligible: true},
+		{Finding: cachecore.Finding{Path: filepath.Join(project, "build")}, ProjectRoot: project, Pattern: "build", Eligible: true},
+	}
+	applyIgnorePolicy(findings)
+
+	if !findings[0].Eligible || findings[0].VCS != cachecore.PathIgnored {
+		t.Errorf("expected ignored node_modules to stay eligible, got %+v", findings[0])
+	}
+	if findings[1].Eligible || !findings[1].Protected || findings[1].VCS != cachecore.PathTracked {
+		t.Errorf("expected tracked vendor to be protected, got %+v", findings[1])
+	}
+	if findings[2].Eligible || findings[2].Protected || findings[2].Reason != "not ignored by .gitignore" {
+		t.Errorf("expected unignored build to be kept, got %+v", findings[2])
+	}
+}
+
+func TestRunRestoreAndPurge(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	cache := filepath.Join(t.TempDir(), "node_modules")
+	if err := os.Mkdir(cache, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	hold := func() {
+		t.Helper()
+		run, err := newQuarantine().Begin(time.Now().Add(-10 * 24 * time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := run.Hold(cache, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hold()
+	if code := runRestore(nil); code != 0 {
+		t.Fatalf("runRestore() = %d", code)
+	}
+	if _, err := os.Stat(cache); err != nil {
+		t.Fatalf("expected cache to be restored: %v", err)
+	}
+
+	hold()
+	if code := runPurge([]string{"--older-than", "30d"}); code != 0 {
+		t.Fatalf("runPurge() = %d", code)
+	}
+	if runs, _ := newQuarantine().Runs(); len(runs) != 1 {
+		t.Fatalf("expected recent run to be kept, got %d runs", len(runs))
+	}
+	if code := runPurge([]string{"--older-than", "7d"}); code != 0 {
+		t.Fatalf("runPurge() = %d", code)
+	}
+	if runs, _ := newQuarantine().Runs(); len(runs) != 0 {
+		t.Fatalf("expected run to be purged, got %d runs", len(runs))
+	}
+}
+
+func TestHistoryRecord(t *testing.T) {
+	rep := Report{Envelope: cachecore.Envelope{When: time.Now(), DryRun: false}}
+	rep.Findings = []Finding{
+		{Finding: cachecore.Finding{Path: "/src/a/node_modules", SizeBytes: 100}, ProjectRoot: "/src/a", Pattern: "node_modules", Language: "node"},
+		{Finding: cachecore.Finding{Path: "/src/a/.venv", SizeBytes: 50}, ProjectRoot: "/src/a", Pattern: ".venv", Language: "python"},
+		{Finding: cachecore.Finding{Path: "/src/b/target", SizeBytes: 25}, Pattern: "target"},
+		{Finding: cachecore.Finding{Path: "/src/c"}, ProjectRoot: "/src/c"},
+	}
+
+	rec := historyRecord(rep, map[string]int64{"/src/a/node_modules": 0}, 100)
+	if rec.Total != 175 || rec.Reclaimed != 100 || rec.DryRun {
+		t.Fatalf("unexpected totals: %+v", rec)
+	}
+	if rec.Groups["language"]["node"] != 100 || rec.Groups["language"]["unknown"] != 25 {
+		t.Fatalf("unexpected language sizes: %v", rec.Groups["language"])
+	}
+	if rec.Groups["project"]["/src/a"] != 150 || rec.Groups["project"]["/src/b"] != 25 {
+		t.Fatalf("unexpected project sizes: %v", rec.Groups["project"])
+	}
+	if len(rec.Paths) != 3 || len(rec.Cleaned) != 1 {
+		t.Fatalf("unexpected paths: %v cleaned: %v", rec.Paths, rec.Cleaned)
+	}
+}
+
+func TestRunHistory(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if code := runHistory(nil); code != 0 {
+		t.Fatalf("runHistory() on empty history = %d", code)
+	}
+	now := time.Now()
+	for _, rec := range []cachecore.HistoryRecord{
+		{When: now.Add(-48 * time.Hour), Total: 100, Groups: map[string]map[string]int64{"language": {"node": 100}}, Paths: map[string]int64{"/a": 100}, Cleaned: map[string]int64{"/a": 0}},
+		{When: now, DryRun: true, Total: 60, Groups: map[string]map[string]int64{"language": {"node": 60}}, Paths: map[string]int64{"/a": 60}},
+	} {
+		if err := newHistory().Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{nil, {"--json"}, {"--limit", "1"}} {
+		if code := runHistory(args); code != 0 {
+			t.Fatalf("runHistory(%v) = %d", args, code)
+		}
+	}
+}
+
+func TestVerifyRemoved(t *testing.T) {
+	root := t.TempDir()
+	gone := filepath.Join(root, "a", "node_modules")
+	partial := filepath.Join(root, "b", "node_modules")
+	if err := os.MkdirAll(partial, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(partial, "left.js"), make([]byte, 40), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed := []Finding{
+		{Finding: cachecore.Finding{Path: gone, SizeBytes: 100}, Pattern: "node_modules"},
+		{Finding: cachecore.Finding{Path: partial, SizeBytes: 60}, Pattern: "node_modules"},
+	}
+	if freed := verifyRemoved(removed); freed != 120 {
+		t.Fatalf("verifyRemoved() = %d, want 120", freed)
+	}
+}
@@ -1,17 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
+
+	"cachecore"
 )
 
 // ----- Version info -----
@@ -23,127 +34,1364 @@ var (
 
 // ----- CLI flags -----
 var (
-	flagScan  = flag.String("scan", "", "Directory to scan for .git directories")
-	flagClean = flag.Bool("clean", false, "Run git gc in each repository and show disk savings")
+	flagScan      = flag.String("scan", "", "Directory to scan for .git directories (default: scanPaths from the config)")
+	flagClean     = flag.Bool("clean", false, "Run the maintenance strategy in each repository and show disk savings")
+	flagJSON      = flag.Bool("json", false, "Print a JSON report instead of tables")
+	flagConfig    = flag.String("config", defaultConfigPath(), "Path to YAML config")
+	flagInit      = flag.Bool("init", false, "Write a starter config to --config and exit")
+	flagForce     = flag.Bool("force", false, "Force overwrite existing config (use with --init)")
+	flagMinSize   = flag.String("min-size", "", "Only gc repositories whose .git is at least this big (e.g. 50MB); overrides config")
+	flagStrat     = flag.String("strategy", "", "Maintenance strategy: auto, maintenance, gc, repack or aggressive; overrides config (default: auto)")
+	flagJobs      = flag.Int("jobs", cachecore.DefaultJobs(), "Max repositories sized or cleaned concurrently")
+	flagTimeout   = flag.Duration("timeout", 30*time.Minute, "Maximum time for the maintenance steps in one repository (0 = no limit)")
+	flagVerbose   = flag.Bool("verbose", false, "Show git output for every repository, not only for failures")
+	flagSort      = flag.String("sort", "saved", "Order of the results table after --clean: saved, before, after or path")
+	flagBreak     = flag.Bool("breakdown", false, "Break down each repository by packs, loose objects, LFS, rerere, logs and hooks, and list what could be reclaimed")
+	flagOnDisk    = flag.Bool("on-disk", false, "Report allocated on-disk usage (like du) instead of apparent size")
+	flagNoIndex   = flag.Bool("no-index", false, "Walk every .git directory instead of reusing unchanged ones from the size index")
+	flagExclude   = cachecore.PatternsFlag("exclude", "Skip directories matching this pattern while scanning, e.g. node_modules or /mnt/* (repeatable)")
+	flagOneFS     = flag.Bool("one-file-system", true, "Stay on each scan path's filesystem and skip mount points below it (--one-file-system=false to cross them)")
+	flagAudit     = flag.Bool("audit", false, "Report local work in each repository and whether it is safe to slim, archive or delete, then exit")
+	flagSlim      = flag.String("slim", "", "Convert inactive repositories in place to a shallow or blobless clone with --clean: shallow or blobless (default: off)")
+	flagSlimDepth = flag.Int("slim-depth", 1, "Commits of history kept per branch by --slim shallow")
+	flagInactive  = flag.String("inactive-for", "180d", "Only --slim repositories with no commits within this age, e.g. 90d (0 = any)")
+)
+
+// ----- Config types -----
+
+type Config struct {
+	Version int            `yaml:"version"`
+	Options Options        `yaml:"options"`
+	Repos   []RepoOverride `yaml:"repos,omitempty"`
+}
+
+type Options struct {
+	ScanPaths   []string `yaml:"scanPaths"`             // Directories scanned when --scan is not given
+	Exclude     []string `yaml:"exclude,omitempty"`     // Path globs skipped while scanning, like --exclude (e.g. "**/vendor/**", "/mnt/*")
+	MinRepoSize string   `yaml:"minRepoSize,omitempty"` // Only gc repositories whose .git is at least this big (e.g. "50MB"); empty = no limit
+
+	Strategy     string     `yaml:"strategy,omitempty"`     // StrategyAuto (default) or a strategy used for every repository
+	Thresholds   Thresholds `yaml:"thresholds,omitempty"`   // When StrategyAuto acts, and how
+	Repack       Repack     `yaml:"repack,omitempty"`       // Delta window and depth for StrategyRepack
+	Housekeeping []string   `yaml:"housekeeping,omitempty"` // Extra steps run before the strategy: reflog, worktrees, remotes, lfs
+	ReflogExpire string     `yaml:"reflogExpire,omitempty"` // Passed to git reflog expire --expire (default "90.days.ago")
+}
+
+// Thresholds are compared against git count-objects -v to pick a strategy.
+// Zero values use the defaults.
+type Thresholds struct {
+	LooseObjects int    `yaml:"looseObjects,omitempty"` // Act when a repository has at least this many loose objects (default 1000)
+	Packs        int    `yaml:"packs,omitempty"`        // Repack when a repository has more packs than this (default 20)
+	LargeRepo    string `yaml:"largeRepo,omitempty"`    // Packs at least this big are repacked incrementally instead of all at once (default "2GB")
+}
+
+type Repack struct {
+	Window int `yaml:"window,omitempty"` // git repack --window (default 250)
+	Depth  int `yaml:"depth,omitempty"`  // git repack --depth (default 50)
+}
+
+// RepoOverride changes what --clean does for the repositories matching Path.
+type RepoOverride struct {
+	Path     string `yaml:"path"`     // Repository path or glob (e.g. "~/src/mirrors/*")
+	Strategy string `yaml:"strategy"` // Any strategy, including StrategyNever
+}
+
+// Strategies for --strategy, the config and Finding.Action.
+const (
+	StrategyAuto        = "auto"        // Chosen per repository from git count-objects -v
+	StrategyMaintenance = "maintenance" // git maintenance run: loose objects, incremental repack, commit graph
+	StrategyGC          = "gc"          // git gc
+	StrategyRepack      = "repack"      // git repack -adf with the configured window and depth
+	StrategyAggressive  = "aggressive"  // git gc --aggressive --prune=now
+	StrategyNever       = "never"       // Reported, never touched
+	StrategySlim        = "slim"        // Set by --slim: refetch as a shallow or blobless clone
 )
 
+var strategies = []string{StrategyAuto, StrategyMaintenance, StrategyGC, StrategyRepack, StrategyAggressive, StrategyNever}
+
+// Modes for --slim.
+const (
+	SlimShallow  = "shallow"  // Keep only the newest --slim-depth commits of each branch
+	SlimBlobless = "blobless" // Keep all commits and trees; fetch file contents from origin on demand
+)
+
+var slimModes = []string{SlimShallow, SlimBlobless}
+
+// Housekeeping steps for Options.Housekeeping.
+var housekeepingSteps = []string{"reflog", "worktrees", "remotes", "lfs"}
+
 // ----- Finding types -----
 
 type Finding struct {
-	Path      string `json:"path"`
-	RepoPath  string `json:"repo_path"` // Parent directory containing .git
-	SizeBytes int64  `json:"size_bytes"`
-	Items     int    `json:"items"`
+	cachecore.Finding
+	RepoPath  string   `json:"repo_path"`           // Where git commands run: the working tree, or the git dir of a bare repository
+	Kind      string   `json:"kind"`                // KindRepo, KindBare or KindSubmodule
+	Worktrees []string `json:"worktrees,omitempty"` // Linked worktrees sharing this repository's objects
+
+	Objects   *ObjectStats `json:"objects,omitempty"`   // From git count-objects -v
+	Action    string       `json:"action"`              // The strategy --clean uses
+	Reason    string       `json:"reason,omitempty"`    // Why StrategyAuto chose Action
+	Skip      string       `json:"skip,omitempty"`      // Why --clean leaves this repository alone
+	Audit     *Audit       `json:"audit,omitempty"`     // Set by --audit and --slim
+	Breakdown *Breakdown   `json:"breakdown,omitempty"` // Set by --breakdown
+	GC        *GCResult    `json:"gc,omitempty"`        // Set by --clean
+}
+
+// ObjectStats is the output of git count-objects -v.
+type ObjectStats struct {
+	Loose       int   `json:"loose"`
+	LooseBytes  int64 `json:"loose_bytes"`
+	Packs       int   `json:"packs"`
+	PackBytes   int64 `json:"pack_bytes"`
+	Garbage     int   `json:"garbage"`
+	Prunable    int   `json:"prunable"` // Loose objects that are also packed
+	InPack      int   `json:"in_pack"`
+	GarbageSize int64 `json:"garbage_bytes"`
+}
+
+// Breakdown splits a git dir by what uses the space and lists what could be
+// reclaimed from it.
+type Breakdown struct {
+	PackBytes   int64 `json:"pack_bytes"`   // objects/pack
+	LooseBytes  int64 `json:"loose_bytes"`  // Loose objects
+	LFSBytes    int64 `json:"lfs_bytes"`    // lfs/objects
+	RerereBytes int64 `json:"rerere_bytes"` // rr-cache
+	LogsBytes   int64 `json:"logs_bytes"`   // Reflogs
+	HooksBytes  int64 `json:"hooks_bytes"`
+	OtherBytes  int64 `json:"other_bytes"` // Index, refs, config and the rest
+
+	UnreachableObjects int      `json:"unreachable_objects"`
+	UnreachableBytes   int64    `json:"unreachable_bytes"`    // On-disk size of the unreachable objects
+	LFSPrunableObjects int      `json:"lfs_prunable_objects"` // LFS objects git lfs prune would delete
+	LFSPrunableBytes   int64    `json:"lfs_prunable_bytes"`
+	MergedBranches     []string `json:"merged_branches"` // Local branches already merged into the default branch
+	Errors             []string `json:"errors,omitempty"`
+}
+
+// Audit is the work in a repository that only exists locally and would be
+// lost by slimming, archiving or deleting it.
+type Audit struct {
+	Changed    int           `json:"changed"`   // Tracked files with uncommitted changes, across all worktrees
+	Untracked  int           `json:"untracked"` // Untracked files that are not ignored, across all worktrees
+	Stashes    int           `json:"stashes"`
+	Unpushed   int           `json:"unpushed_commits"` // Commits on local branches or tags that no remote-tracking branch (for a mirror, no ref on origin) contains
+	Ahead      []BranchAhead `json:"ahead"`            // Local branches with commits their upstream does not have
+	NoUpstream []string      `json:"no_upstream"`      // Local branches without an upstream, or whose upstream is gone
+	HasOrigin  bool          `json:"has_origin"`
+	Mirror     bool          `json:"mirror,omitempty"`      // A git clone --mirror, whose refs are origin's refs/*
+	OriginTags []string      `json:"origin_tags,omitempty"` // Local tags origin has at the same object, dropped by --slim shallow
+	LastCommit time.Time     `json:"last_commit,omitempty"` // Newest commit on any local branch
+	Errors     []string      `json:"errors,omitempty"`
+
+	SafeToSlim    bool `json:"safe_to_slim"`    // Nothing but history origin has would be lost by refetching
+	SafeToArchive bool `json:"safe_to_archive"` // No changed or untracked files: the git dir holds all the work
+	SafeToDelete  bool `json:"safe_to_delete"`  // Safe to slim, and every branch tracks an upstream it is not ahead of
+}
+
+// BranchAhead is a local branch ahead of its upstream.
+type BranchAhead struct {
+	Branch  string `json:"branch"`
+	Commits int    `json:"commits"`
+}
+
+// GCResult records what --clean did to one repository.
+type GCResult struct {
+	OK         bool     `json:"ok"`
+	Error      string   `json:"error,omitempty"`
+	Steps      []string `json:"steps"` // The git commands run
+	AfterBytes int64    `json:"after_bytes"`
+	AfterItems int      `json:"after_items"`
+	SavedBytes int64    `json:"saved_bytes"`      // Negative when the repository grew
+	Output     string   `json:"output,omitempty"` // git output; kept on failure or with --verbose
+}
+
+type Report struct {
+	cachecore.Envelope
+	ScanPaths   []string                 `json:"scan_paths"`
+	MinRepoSize int64                    `json:"min_repo_size_bytes,omitempty"`
+	Strategy    string                   `json:"strategy"`
+	Slim        string                   `json:"slim,omitempty"` // --slim mode
+	TotalBefore int64                    `json:"total_before_bytes"`
+	TotalAfter  int64                    `json:"total_after_bytes,omitempty"`
+	Saved       int64                    `json:"saved_bytes"`
+	Cleaned     int                      `json:"cleaned"`
+	Failed      int                      `json:"failed"`
+	Grew        int                      `json:"grew"`
+	Findings    []Finding                `json:"findings"`
+	Excluded    []cachecore.ExcludedPath `json:"excluded"` // Directories the scan skipped
+	Warnings    []string                 `json:"warnings"`
 }
 
 // ----- Utilities -----
 
-func human(n int64) string {
-	if n < 1024 {
-		return fmt.Sprintf("%d B", n)
+func defaultConfigPath() string { return cachecore.DefaultConfigPath("git-cleaner") }
+
+var (
+	human            = cachecore.Human
+	checkVersionFlag = cachecore.CheckVersionFlag
+)
+
+// newSizer returns a directory sizer configured from --jobs, --on-disk, --no-index and the exclusions.
+func newSizer() cachecore.Sizer {
+	return cachecore.Sizer{Jobs: *flagJobs, OnDisk: *flagOnDisk, Index: sizeIndex, Exclude: sizeExclude}
+}
+
+// sizeIndex caches directory totals between runs; nil with --no-index.
+var sizeIndex *cachecore.Index
+
+// sizeExclude is the excluder passed to the scan, so that sizes leave out the same
+// directories; nil counts everything.
+var sizeExclude *cachecore.Excluder
+
+func inspectPath(root string) (Finding, error) {
+	u, err := cachecore.Inspect(root)
+	return Finding{Finding: u}, err
+}
+
+// scanDirectory walks through the directory tree and finds all git repositories,
+// skipping the directories exclude rules out and, unless boundary is nil,
+// mount points of other filesystems.
+// The git dirs are sized after the walk using up to --jobs concurrent walkers.
+func scanDirectory(root string, exclude *cachecore.Excluder, boundary *cachecore.Boundary) []Finding {
+	return scanRoots([]string{root}, exclude, boundary)
+}
+
+// scanRoots scans each root and sizes every object store found once, even
+// when roots overlap or several worktrees share it.
+func scanRoots(roots []string, exclude *cachecore.Excluder, boundary *cachecore.Boundary) []Finding {
+	set := &repoSet{stores: map[string]*repoStore{}}
+	for _, root := range roots {
+		set.discover(root, exclude, boundary)
 	}
-	units := []string{"KB", "MB", "GB", "TB"}
-	v := float64(n)
-	for i, u := range units {
-		v /= 1024
-		if v < 1024 || i == len(units)-1 {
-			return fmt.Sprintf("%.2f %s", v, u)
+	return set.measure()
+}
+
+// Kinds of repository in Finding.Kind.
+const (
+	KindRepo      = "repo"      // A git dir with a working tree
+	KindBare      = "bare"      // A bare repository (*.git with HEAD and objects)
+	KindSubmodule = "submodule" // A submodule's git dir inside its superproject's .git/modules
+)
+
+// repoStore is one object store: the common git dir that holds the objects
+// and refs shared by a repository's worktrees.
+type repoStore struct {
+	gitDir   string
+	repoPath string // Where git commands run
+	kind     string
+}
+
+// repoSet collects the object stores found while scanning, in discovery order.
+type repoSet struct {
+	order  []string
+	stores map[string]*repoStore
+}
+
+// add records the object store at gitDir. repoPath is its working tree, or ""
+// if unknown; a store first seen without one runs git in its git dir until a
+// working tree turns up.
+func (s *repoSet) add(gitDir, repoPath, kind string) {
+	gitDir = absPath(gitDir)
+	if st, ok := s.stores[gitDir]; ok {
+		if repoPath != "" && st.repoPath == st.gitDir {
+			st.repoPath = repoPath
 		}
+		return
 	}
-	return fmt.Sprintf("%.2f TB", v/1024)
+	if repoPath == "" {
+		repoPath = gitDir
+		if filepath.Base(gitDir) == ".git" {
+			repoPath = filepath.Dir(gitDir)
+		}
+	}
+	s.order = append(s.order, gitDir)
+	s.stores[gitDir] = &repoStore{gitDir: gitDir, repoPath: repoPath, kind: kind}
 }
 
-func checkVersionFlag() bool {
-	for _, arg := range os.Args[1:] {
-		if arg == "-version" || arg == "--version" {
-			return true
+// discover walks root for .git directories, .git files (linked worktrees,
+// submodules and separate git dirs) and bare repositories.
+func (s *repoSet) discover(root string, exclude *cachecore.Excluder, boundary *cachecore.Boundary) {
+	root = absPath(root)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Continue on errors
 		}
+
+		// A .git file points at the real git dir
+		if d.Name() == ".git" && d.Type().IsRegular() {
+			gitDir, err := readGitFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				return nil
+			}
+			if common := commonDir(gitDir); common != "" {
+				// A linked worktree belongs to the repository that owns the objects
+				s.add(common, "", kindOf(common))
+			} else {
+				s.add(gitDir, filepath.Dir(path), kindOf(gitDir))
+			}
+			return nil
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if path != root && (exclude.Skip(path, true) || boundary.Crosses(path, d)) {
+			return filepath.SkipDir
+		}
+
+		switch {
+		case d.Name() == ".git":
+			s.add(path, filepath.Dir(path), KindRepo)
+			// Submodule git dirs live under .git/modules, whether or not they are checked out
+			for _, m := range findModules(path) {
+				s.add(m, "", KindSubmodule)
+			}
+			return filepath.SkipDir
+		case strings.HasSuffix(d.Name(), ".git") && isGitDir(path):
+			s.add(path, path, KindBare)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		// Log error but continue - return what we found so far
+		fmt.Fprintf(os.Stderr, "Warning: error walking directory: %v\n", err)
 	}
-	return false
 }
 
-func inspectPath(root string) (Finding, error) {
-	f := Finding{Path: root}
-	fi, err := os.Stat(root)
+// measure sizes every object store and attaches its kind and linked worktrees.
+func (s *repoSet) measure() []Finding {
+	findings := measureGitDirs(s.order)
+	for i := range findings {
+		f := &findings[i]
+		st := s.stores[f.Path]
+		f.RepoPath, f.Kind, f.Worktrees = st.repoPath, st.kind, linkedWorktrees(st.gitDir)
+	}
+	return findings
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// readGitFile returns the git dir named by a "gitdir: <path>" .git file,
+// resolved relative to the file's directory.
+func readGitFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
 	if err != nil {
-		return f, err
+		return "", err
 	}
-	if !fi.IsDir() {
-		f.Items = 1
-		f.SizeBytes = fi.Size()
-		return f, nil
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "gitdir: ")
+	if !ok || dir == "" {
+		return "", fmt.Errorf("%s: not a gitdir file", path)
 	}
-	errWalk := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-		if d.IsDir() {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(path), dir)
+	}
+	return absPath(dir), nil
+}
+
+// commonDir returns the git dir a linked worktree's git dir shares its
+// objects with, or "" if gitDir is not a linked worktree.
+func commonDir(gitDir string) string {
+	b, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return ""
+	}
+	dir := strings.TrimSpace(string(b))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return absPath(dir)
+}
+
+// kindOf classifies a git dir reached through a .git file: one inside a
+// superproject's modules directory is a submodule, one whose config says it
+// is bare (a bare repository with linked worktrees) is bare.
+func kindOf(gitDir string) string {
+	if strings.Contains(filepath.ToSlash(gitDir), "/modules/") {
+		return KindSubmodule
+	}
+	if b, err := os.ReadFile(filepath.Join(gitDir, "config")); err == nil && strings.Contains(string(b), "bare = true") {
+		return KindBare
+	}
+	return KindRepo
+}
+
+// isGitDir reports whether dir looks like a git dir: a HEAD file and an objects directory.
+func isGitDir(dir string) bool {
+	head, err := os.Stat(filepath.Join(dir, "HEAD"))
+	if err != nil || head.IsDir() {
+		return false
+	}
+	objects, err := os.Stat(filepath.Join(dir, "objects"))
+	return err == nil && objects.IsDir()
+}
+
+// findModules returns the submodule git dirs under gitDir/modules, including
+// nested submodules.
+func findModules(gitDir string) []string {
+	var found []string
+	root := filepath.Join(gitDir, "modules")
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == root {
 			return nil
 		}
-		info, e := d.Info()
-		if e != nil {
-			return nil
+		if isGitDir(path) {
+			found = append(found, path)
+			found = append(found, findModules(path)...)
+			return filepath.SkipDir
 		}
-		f.Items++
-		f.SizeBytes += info.Size()
 		return nil
 	})
-	return f, errWalk
+	return found
 }
 
-// scanDirectory walks through the directory tree and finds all .git directories
-func scanDirectory(root string) []Finding {
-	var findings []Finding
-
-	root = filepath.Clean(root)
-	rootAbs, err := filepath.Abs(root)
-	if err == nil {
-		root = rootAbs
+// linkedWorktrees lists the working trees registered in gitDir/worktrees.
+func linkedWorktrees(gitDir string) []string {
+	entries, err := os.ReadDir(filepath.Join(gitDir, "worktrees"))
+	if err != nil {
+		return nil
 	}
+	var worktrees []string
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(gitDir, "worktrees", e.Name(), "gitdir"))
+		if err != nil {
+			continue
+		}
+		// The gitdir file names the worktree's .git file
+		worktrees = append(worktrees, filepath.Dir(strings.TrimSpace(string(b))))
+	}
+	return worktrees
+}
 
-	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+// measureGitDirs sizes the given git dirs, skipping any that cannot be read.
+// A superproject's modules directory is left out, since each submodule's git
+// dir is measured as a repository of its own.
+func measureGitDirs(gitDirs []string) []Finding {
+	var paths []string
+	var owner []int
+	findings := make([]Finding, 0, len(gitDirs))
+	for _, dir := range gitDirs {
+		entries, err := os.ReadDir(dir)
 		if err != nil {
-			return nil // Continue on errors
+			continue
+		}
+		// The repository path is the parent of .git
+		findings = append(findings, Finding{Finding: cachecore.Finding{Path: dir}, RepoPath: filepath.Dir(dir)})
+		for _, e := range entries {
+			if e.Name() == "modules" && e.IsDir() {
+				continue
+			}
+			paths = append(paths, filepath.Join(dir, e.Name()))
+			owner = append(owner, len(findings)-1)
 		}
+	}
 
-		if !d.IsDir() {
-			return nil
+	sized, errs := newSizer().InspectAll(paths)
+	for i, u := range sized {
+		if errs[i] != nil {
+			continue
+		}
+		f := &findings[owner[i]].Finding
+		f.SizeBytes += u.SizeBytes
+		f.ApparentBytes += u.ApparentBytes
+		f.DiskBytes += u.DiskBytes
+		f.Items += u.Items
+		if u.ModMax.After(f.ModMax) {
+			f.ModMax = u.ModMax
 		}
+		if f.Err == "" {
+			f.Err = u.Err
+		}
+	}
+	return findings
+}
+
+// forEachJob calls fn for each index in [0, n), up to --jobs at a time.
+func forEachJob(n int, fn func(i int)) {
+	jobs := *flagJobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
 
-		// Check if this directory is named .git
-		if d.Name() == ".git" {
-			// Get the repository path (parent of .git)
-			repoPath := filepath.Dir(path)
+// breakDown sets the Breakdown of each finding.
+func breakDown(findings []Finding) {
+	forEachJob(len(findings), func(i int) {
+		findings[i].Breakdown = readBreakdown(findings[i])
+	})
+}
 
-			// Inspect the .git directory
-			f, err := inspectPath(path)
-			if err != nil {
-				return nil
+// readBreakdown sizes the parts of a repository's git dir and asks git for
+// unreachable objects, prunable LFS objects and merged branches. A check that
+// fails is recorded in Errors without stopping the others.
+func readBreakdown(f Finding) *Breakdown {
+	b := &Breakdown{MergedBranches: []string{}}
+	parts := []string{"objects", "objects/pack", "objects/info", "lfs/objects", "rr-cache", "logs", "hooks"}
+	paths := make([]string, len(parts))
+	for i, part := range parts {
+		paths[i] = filepath.Join(f.Path, filepath.FromSlash(part))
+	}
+	size := map[string]int64{}
+	sized, errs := newSizer().InspectAll(paths)
+	for i, u := range sized {
+		if errs[i] == nil {
+			size[parts[i]] = u.SizeBytes
+		}
+	}
+	b.PackBytes = size["objects/pack"]
+	b.LooseBytes = size["objects"] - size["objects/pack"] - size["objects/info"]
+	b.LFSBytes = size["lfs/objects"]
+	b.RerereBytes = size["rr-cache"]
+	b.LogsBytes = size["logs"]
+	b.HooksBytes = size["hooks"]
+	b.OtherBytes = f.SizeBytes - b.PackBytes - b.LooseBytes - b.LFSBytes - b.RerereBytes - b.LogsBytes - b.HooksBytes
+
+	fail := func(check string, err error) {
+		b.Errors = append(b.Errors, fmt.Sprintf("%s: %v", check, err))
+	}
+	var err error
+	if b.UnreachableObjects, b.UnreachableBytes, err = unreachableObjects(f.Path); err != nil {
+		fail("unreachable objects", err)
+	}
+	if b.LFSBytes > 0 {
+		if b.LFSPrunableObjects, b.LFSPrunableBytes, err = lfsPrunable(f.RepoPath); err != nil {
+			fail("lfs prune --dry-run", err)
+		}
+	}
+	if b.MergedBranches, err = mergedBranches(f.Path); err != nil {
+		b.MergedBranches = []string{}
+		fail("merged branches", err)
+	}
+	return b
+}
+
+// unreachableObjects counts the objects no ref or reflog can reach, and their
+// on-disk size, via git fsck and git cat-file.
+func unreachableObjects(gitDir string) (int, int64, error) {
+	out, err := exec.Command("git", "--git-dir="+gitDir, "fsck", "--unreachable", "--no-progress").Output()
+	if err != nil {
+		return 0, 0, err
+	}
+	var ids []string
+	for _, line := range strings.Split(string(out), "\n") {
+		// e.g. "unreachable blob 0123abcd..."
+		if fields := strings.Fields(line); len(fields) == 3 && fields[0] == "unreachable" {
+			ids = append(ids, fields[2])
+		}
+	}
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+	cmd := exec.Command("git", "--git-dir="+gitDir, "cat-file", "--batch-check=%(objectsize:disk)")
+	cmd.Stdin = strings.NewReader(strings.Join(ids, "\n") + "\n")
+	out, err = cmd.Output()
+	if err != nil {
+		return len(ids), 0, err
+	}
+	var total int64
+	for _, line := range strings.Fields(string(out)) {
+		n, _ := strconv.ParseInt(line, 10, 64)
+		total += n
+	}
+	return len(ids), total, nil
+}
+
+// lfsPrunable runs git lfs prune --dry-run in the repository.
+func lfsPrunable(repoPath string) (int, int64, error) {
+	cmd := exec.Command("git", "lfs", "prune", "--dry-run")
+	cmd.Dir = repoPath
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	n, size := parseLFSPrune(string(out))
+	return n, size, nil
+}
+
+// lfsPruneLine matches git lfs prune --dry-run's summary, e.g.
+// "prune: 3 files would be pruned (12 MB)".
+var lfsPruneLine = regexp.MustCompile(`(\d+) files? would be pruned \(([^)]+)\)`)
+
+// parseLFSPrune returns the object count and size from git lfs prune --dry-run output.
+func parseLFSPrune(out string) (int, int64) {
+	m := lfsPruneLine.FindStringSubmatch(out)
+	if m == nil {
+		return 0, 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	size, _ := cachecore.ParseSize(m[2])
+	return n, size
+}
+
+// mergedBranches lists local branches already merged into the default
+// branch: origin's HEAD if known, else the checked-out branch. The default
+// and checked-out branches themselves are left out.
+func mergedBranches(gitDir string) ([]string, error) {
+	git := func(args ...string) (string, error) {
+		out, err := exec.Command("git", append([]string{"--git-dir=" + gitDir}, args...)...).Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	current, _ := git("symbolic-ref", "--quiet", "--short", "HEAD")
+	base, err := git("symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD")
+	if err != nil || base == "" {
+		if current == "" {
+			return []string{}, nil // Detached HEAD and no remote default
+		}
+		base = current
+	}
+	out, err := git("for-each-ref", "--merged="+base, "--format=%(refname:short)", "refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	merged := []string{}
+	for _, branch := range strings.Fields(out) {
+		if branch != current && branch != base && "origin/"+branch != base {
+			merged = append(merged, branch)
+		}
+	}
+	return merged, nil
+}
+
+// displayBreakdown shows where each repository's space goes and what could be
+// reclaimed, largest repositories first.
+func displayBreakdown(findings []Finding) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Repository Path", "Packs", "Loose", "LFS", "Rerere", "Logs", "Hooks", "Other", "Unreachable", "LFS Prunable", "Merged Branches")
+
+	sorted := make([]Finding, len(findings))
+	copy(sorted, findings)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SizeBytes > sorted[j].SizeBytes
+	})
+	for _, f := range sorted {
+		b := f.Breakdown
+		if b == nil {
+			continue
+		}
+		if err := table.Append(repoLabel(f), human(b.PackBytes), human(b.LooseBytes), human(b.LFSBytes), human(b.RerereBytes), human(b.LogsBytes), human(b.HooksBytes), human(b.OtherBytes),
+			fmt.Sprintf("%d (%s)", b.UnreachableObjects, human(b.UnreachableBytes)),
+			fmt.Sprintf("%d (%s)", b.LFSPrunableObjects, human(b.LFSPrunableBytes)),
+			strings.Join(b.MergedBranches, ", ")); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: error appending to table: %v\n", err)
+		}
+	}
+	if err := table.Render(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+	}
+	for _, f := range sorted {
+		if f.Breakdown != nil {
+			for _, e := range f.Breakdown.Errors {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", f.RepoPath, e)
 			}
-			f.RepoPath = repoPath
-			findings = append(findings, f)
+		}
+	}
+}
 
-			// Skip subdirectories of .git
-			return filepath.SkipDir
+// displayAudit shows the local work in each repository and what is safe to do
+// with it, repositories that are not safe to delete first.
+func displayAudit(findings []Finding) {
+	sorted := make([]Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if a, b := sorted[i].Audit.SafeToDelete, sorted[j].Audit.SafeToDelete; a != b {
+			return b
 		}
+		return sorted[i].RepoPath < sorted[j].RepoPath
+	})
 
-		return nil
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Repository Path", "Changed", "Untracked", "Stashes", "Unpushed", "Ahead", "No Upstream", "Last Commit", "Safe To")
+	var slim, archive, del int
+	now := time.Now()
+	for _, f := range sorted {
+		a := f.Audit
+		ahead := make([]string, len(a.Ahead))
+		for i, b := range a.Ahead {
+			ahead[i] = fmt.Sprintf("%s +%d", b.Branch, b.Commits)
+		}
+		last := "-"
+		if !a.LastCommit.IsZero() {
+			last = cachecore.HumanAge(now.Sub(a.LastCommit)) + " ago"
+		}
+		var safe []string
+		if a.SafeToSlim {
+			safe = append(safe, "slim")
+			slim++
+		}
+		if a.SafeToArchive {
+			safe = append(safe, "archive")
+			archive++
+		}
+		if a.SafeToDelete {
+			safe = append(safe, "delete")
+			del++
+		}
+		if err := table.Append(repoLabel(f), fmt.Sprintf("%d", a.Changed), fmt.Sprintf("%d", a.Untracked), fmt.Sprintf("%d", a.Stashes),
+			fmt.Sprintf("%d", a.Unpushed), dashIfEmpty(ahead), dashIfEmpty(a.NoUpstream), last, dashIfEmpty(safe)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: error appending to table: %v\n", err)
+		}
+	}
+	table.Footer("TOTAL", "", "", "", "", "", "", "", fmt.Sprintf("%d slim, %d archive, %d delete", slim, archive, del))
+	if err := table.Render(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+	}
+
+	for _, f := range sorted {
+		for _, e := range f.Audit.Errors {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", f.RepoPath, e)
+		}
+	}
+}
+
+// dashIfEmpty joins a list for a table cell.
+func dashIfEmpty(list []string) string {
+	if len(list) == 0 {
+		return "-"
+	}
+	return strings.Join(list, ", ")
+}
+
+// gcPolicy is the resolved maintenance configuration.
+type gcPolicy struct {
+	strategy     string
+	minSize      int64
+	looseObjects int
+	packs        int
+	largeRepo    int64
+	window       int
+	depth        int
+	housekeeping []string
+	reflogExpire string
+	timeout      time.Duration
+	slim         string        // --slim mode; "" leaves StrategySlim unused
+	slimDepth    int           // --slim-depth
+	inactiveFor  time.Duration // --inactive-for
+}
+
+// newPolicy resolves the config and --min-size/--strategy flag values into a
+// gcPolicy, filling in defaults and rejecting unknown names.
+func newPolicy(cfg *Config, minSizeFlag, strategyFlag string) (gcPolicy, error) {
+	o := cfg.Options
+	p := gcPolicy{
+		strategy:     o.Strategy,
+		looseObjects: o.Thresholds.LooseObjects,
+		packs:        o.Thresholds.Packs,
+		window:       o.Repack.Window,
+		depth:        o.Repack.Depth,
+		housekeeping: o.Housekeeping,
+		reflogExpire: o.ReflogExpire,
+	}
+	if strategyFlag != "" {
+		p.strategy = strategyFlag
+	}
+	if p.strategy == "" {
+		p.strategy = StrategyAuto
+	}
+	if !slices.Contains(strategies, p.strategy) {
+		return p, fmt.Errorf("unknown strategy %q (use one of %s)", p.strategy, strings.Join(strategies, ", "))
+	}
+	for _, r := range cfg.Repos {
+		if !slices.Contains(strategies, r.Strategy) {
+			return p, fmt.Errorf("unknown strategy %q for %s (use one of %s)", r.Strategy, r.Path, strings.Join(strategies, ", "))
+		}
+	}
+	for _, h := range p.housekeeping {
+		if !slices.Contains(housekeepingSteps, h) {
+			return p, fmt.Errorf("unknown housekeeping step %q (use one of %s)", h, strings.Join(housekeepingSteps, ", "))
+		}
+	}
+
+	minSizeStr := o.MinRepoSize
+	if minSizeFlag != "" {
+		minSizeStr = minSizeFlag
+	}
+	var err error
+	if p.minSize, err = cachecore.ParseSize(minSizeStr); err != nil {
+		return p, err
+	}
+	largeRepo := o.Thresholds.LargeRepo
+	if largeRepo == "" {
+		largeRepo = "2GB"
+	}
+	if p.largeRepo, err = cachecore.ParseSize(largeRepo); err != nil {
+		return p, err
+	}
+
+	if p.looseObjects <= 0 {
+		p.looseObjects = 1000
+	}
+	if p.packs <= 0 {
+		p.packs = 20
+	}
+	if p.window <= 0 {
+		p.window = 250
+	}
+	if p.depth <= 0 {
+		p.depth = 50
+	}
+	if p.reflogExpire == "" {
+		p.reflogExpire = "90.days.ago"
+	}
+	return p, nil
+}
+
+// choose picks a strategy for a repository from its object counts. It
+// returns "" when the repository is healthy and nothing needs to run.
+func (p gcPolicy) choose(s ObjectStats) (strategy, reason string) {
+	switch {
+	case s.Packs > p.packs && s.PackBytes >= p.largeRepo:
+		return StrategyMaintenance, fmt.Sprintf("%d packs, %s packed", s.Packs, human(s.PackBytes))
+	case s.Packs > p.packs:
+		return StrategyRepack, fmt.Sprintf("%d packs", s.Packs)
+	case s.Loose >= p.looseObjects:
+		return StrategyGC, fmt.Sprintf("%d loose objects", s.Loose)
+	case s.Garbage > 0 || s.Prunable > 0:
+		return StrategyGC, fmt.Sprintf("%d garbage files, %d prunable objects", s.Garbage, s.Prunable)
+	}
+	return "", ""
+}
+
+// commands returns the git commands for strategy, preceded by the
+// configured housekeeping steps. The audit, if any, is used by --slim.
+func (p gcPolicy) commands(strategy string, a *Audit) [][]string {
+	var cmds [][]string
+	for _, h := range p.housekeeping {
+		switch h {
+		case "worktrees":
+			cmds = append(cmds, []string{"worktree", "prune"})
+		case "remotes":
+			cmds = append(cmds, []string{"remote", "prune", "origin"})
+		case "reflog":
+			cmds = append(cmds, []string{"reflog", "expire", "--expire=" + p.reflogExpire, "--all"})
+		case "lfs":
+			cmds = append(cmds, []string{"lfs", "prune"})
+		}
+	}
+	switch strategy {
+	case StrategyMaintenance:
+		cmds = append(cmds, []string{"maintenance", "run", "--task=loose-objects", "--task=incremental-repack", "--task=commit-graph"})
+	case StrategyGC:
+		cmds = append(cmds, []string{"gc"})
+	case StrategyRepack:
+		cmds = append(cmds, []string{"repack", "-adf", fmt.Sprintf("--window=%d", p.window), fmt.Sprintf("--depth=%d", p.depth)})
+	case StrategyAggressive:
+		cmds = append(cmds, []string{"gc", "--aggressive", "--prune=now"})
+	case StrategySlim:
+		cmds = append(cmds, p.slimCommands(a)...)
+	}
+	return cmds
+}
+
+// slimCommands refetches a repository as a shallow or blobless clone of origin.
+// Remotes, branches and worktrees stay as they are; the history or blobs the
+// new clone no longer needs are dropped by the final gc or repack.
+//
+// Every ref still pointing into the old history keeps it, so a shallow slim
+// fetches without tags, prunes remote-tracking refs of branches origin no
+// longer has and deletes the local tags origin has too. A mirror fetches all
+// of origin's refs/* at the new depth, which covers its tags.
+func (p gcPolicy) slimCommands(a *Audit) [][]string {
+	switch p.slim {
+	case SlimShallow:
+		cmds := [][]string{{"fetch", fmt.Sprintf("--depth=%d", p.slimDepth), "--update-shallow", "--no-tags", "--prune", "origin"}}
+		if a != nil && len(a.OriginTags) > 0 {
+			cmds = append(cmds, append([]string{"tag", "-d"}, a.OriginTags...))
+		}
+		return append(cmds,
+			[]string{"reflog", "expire", "--expire=now", "--all"},
+			[]string{"gc", "--prune=now"},
+		)
+	case SlimBlobless:
+		return [][]string{
+			{"config", "remote.origin.promisor", "true"},
+			{"config", "remote.origin.partialclonefilter", "blob:none"},
+			{"reflog", "expire", "--expire=now", "--all"},
+			{"repack", "-a", "-d", "--filter=blob:none"},
+		}
+	}
+	return nil
+}
+
+// audit sets the Audit of each finding, up to --jobs repositories at a time.
+func audit(findings []Finding) {
+	forEachJob(len(findings), func(i int) {
+		findings[i].Audit = readAudit(findings[i])
 	})
+}
+
+// readAudit finds the work in a repository that only exists locally: changes
+// and untracked files in its worktrees, stashes, commits that no
+// remote-tracking branch contains, and branches ahead of or without an
+// upstream. Errors are recorded on the audit and make nothing safe.
+//
+// A mirror has no remote-tracking branches, so its commits are compared with
+// the refs on origin instead. Origin is also asked for its tags when there are
+// local ones; if it cannot be reached, no tags are listed as on origin.
+func readAudit(f Finding) *Audit {
+	a := &Audit{Ahead: []BranchAhead{}, NoUpstream: []string{}}
+	git := func(args ...string) (string, error) {
+		out, err := exec.Command("git", append([]string{"--git-dir=" + f.Path}, args...)...).Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	fail := func(what string, err error) {
+		a.Errors = append(a.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+
+	var worktrees []string
+	if f.Kind != KindBare && f.RepoPath != f.Path {
+		worktrees = append(worktrees, f.RepoPath)
+	}
+	for _, wt := range append(worktrees, f.Worktrees...) {
+		g, err := cachecore.ReadGitActivity(wt)
+		if err != nil {
+			fail(wt, err)
+			continue
+		}
+		a.Changed += g.Changed
+		a.Untracked += g.Untracked
+	}
+
+	// Linked worktrees share refs/stash, so it is counted once for the repository
+	if out, err := git("stash", "list"); err == nil && out != "" {
+		a.Stashes = len(strings.Split(out, "\n"))
+	}
+	url, _ := git("config", "--get", "remote.origin.url")
+	a.HasOrigin = url != ""
+	mirror, _ := git("config", "--bool", "--get", "remote.origin.mirror")
+	a.Mirror = a.HasOrigin && mirror == "true"
+	tags, err := git("for-each-ref", "--format=%(objectname)%09%(refname)", "refs/tags/")
 	if err != nil {
-		// Log error but continue - return what we found so far
-		fmt.Fprintf(os.Stderr, "Warning: error walking directory: %v\n", err)
+		fail("git for-each-ref", err)
+	}
+	var origin map[string]string
+	if a.Mirror || (a.HasOrigin && tags != "") {
+		out, err := git("ls-remote", "origin")
+		switch {
+		case err == nil:
+			origin = parseRefs(out)
+		case a.Mirror:
+			fail("git ls-remote origin", err)
+		}
 	}
 
-	return findings
+	if a.Mirror {
+		if origin != nil {
+			if n, err := unpushedFrom(f.Path, origin); err != nil {
+				fail("git rev-list", err)
+			} else {
+				a.Unpushed = n
+			}
+		}
+	} else if out, err := git("rev-list", "--count", "--branches", "--tags", "--not", "--remotes"); err != nil {
+		fail("git rev-list", err)
+	} else {
+		a.Unpushed, _ = strconv.Atoi(out)
+	}
+	if !a.Mirror {
+		for ref, oid := range parseRefs(tags) {
+			if origin[ref] == oid {
+				a.OriginTags = append(a.OriginTags, strings.TrimPrefix(ref, "refs/tags/"))
+			}
+		}
+		sort.Strings(a.OriginTags)
+	}
+	if ts, err := git("for-each-ref", "--sort=-committerdate", "--count=1", "--format=%(committerdate:unix)", "refs/heads/"); err == nil && ts != "" {
+		if secs, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			a.LastCommit = time.Unix(secs, 0)
+		}
+	}
+	if out, err := git("for-each-ref", "--format=%(refname:short)%09%(upstream:short)%09%(upstream:track,nobracket)", "refs/heads/"); err != nil {
+		fail("git for-each-ref", err)
+	} else {
+		a.Ahead, a.NoUpstream = parseBranches(out)
+	}
+
+	if len(a.Errors) == 0 {
+		a.SafeToArchive = a.Changed == 0 && a.Untracked == 0
+		a.SafeToSlim = len(a.localWork()) == 0 && a.HasOrigin
+		a.SafeToDelete = a.SafeToSlim && len(a.Ahead) == 0 && len(a.NoUpstream) == 0
+	}
+	return a
 }
 
-// runGitGC runs git gc in the specified repository directory
-func runGitGC(repoPath string) error {
-	cmd := exec.Command("git", "gc")
-	cmd.Dir = repoPath
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// parseRefs parses lines of object name and ref name, as printed by
+// git ls-remote, into a map from ref name to object name.
+func parseRefs(out string) map[string]string {
+	refs := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if oid, ref, ok := strings.Cut(line, "\t"); ok {
+			refs[ref] = oid
+		}
+	}
+	return refs
+}
+
+// unpushedFrom counts the commits on local branches and tags of gitDir that
+// none of the given refs contain. Objects it does not have yet are ignored.
+func unpushedFrom(gitDir string, refs map[string]string) (int, error) {
+	var in strings.Builder
+	for _, oid := range refs {
+		in.WriteString("^" + oid + "\n")
+	}
+	cmd := exec.Command("git", "--git-dir="+gitDir, "rev-list", "--count", "--branches", "--tags", "--ignore-missing", "--stdin")
+	cmd.Stdin = strings.NewReader(in.String())
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+// parseBranches parses git for-each-ref lines of branch, upstream and
+// upstream tracking ("ahead 2, behind 1", "gone" or empty).
+func parseBranches(out string) (ahead []BranchAhead, noUpstream []string) {
+	ahead, noUpstream = []BranchAhead{}, []string{}
+	for _, line := range strings.Split(out, "\n") {
+		// Trailing empty fields may have been trimmed off the last line
+		fields := append(strings.SplitN(line, "\t", 3), "", "")
+		if fields[0] == "" {
+			continue
+		}
+		branch, upstream, track := fields[0], fields[1], fields[2]
+		if upstream == "" || track == "gone" {
+			noUpstream = append(noUpstream, branch)
+			continue
+		}
+		for _, part := range strings.Split(track, ", ") {
+			if n, ok := strings.CutPrefix(part, "ahead "); ok {
+				commits, _ := strconv.Atoi(n)
+				ahead = append(ahead, BranchAhead{Branch: branch, Commits: commits})
+			}
+		}
+	}
+	return ahead, noUpstream
+}
+
+// localWork lists the local-only work an audit found; empty means slimming
+// the repository loses nothing that origin does not have.
+func (a *Audit) localWork() []string {
+	var work []string
+	for _, c := range []struct {
+		n    int
+		what string
+	}{
+		{a.Changed, "changed files"},
+		{a.Untracked, "untracked files"},
+		{a.Stashes, "stashes"},
+		{a.Unpushed, "unpushed commits"},
+	} {
+		if c.n > 0 {
+			work = append(work, fmt.Sprintf("%d %s", c.n, c.what))
+		}
+	}
+	return work
+}
+
+// slimSkip returns why a repository must not be slimmed, or "" if it is safe.
+// Anything that only exists locally, a missing origin, recent commits or a
+// failed audit rules it out.
+func (p gcPolicy) slimSkip(f Finding, now time.Time) string {
+	a := f.Audit
+	if len(a.Errors) > 0 {
+		return "audit failed: " + strings.Join(a.Errors, "; ")
+	}
+	if work := a.localWork(); len(work) > 0 {
+		return "local work: " + strings.Join(work, ", ")
+	}
+	if !a.HasOrigin {
+		return "no origin remote to refetch from"
+	}
+	if age := now.Sub(a.LastCommit); p.inactiveFor > 0 && age < p.inactiveFor {
+		return "active: last commit " + cachecore.HumanAge(age) + " ago"
+	}
+	switch p.slim {
+	case SlimShallow:
+		if _, err := os.Stat(filepath.Join(f.Path, "shallow")); err == nil {
+			return "already shallow"
+		}
+	case SlimBlobless:
+		if !gitAtLeast(2, 43) {
+			return "blobless slimming needs git 2.43 or later"
+		}
+		if out, _ := exec.Command("git", "--git-dir="+f.Path, "config", "--get", "remote.origin.promisor").Output(); strings.TrimSpace(string(out)) == "true" {
+			return "already blobless"
+		}
+	}
+	return ""
+}
+
+// gitAtLeast reports whether the installed git is at least major.minor.
+func gitAtLeast(major, minor int) bool {
+	out, err := exec.Command("git", "version").Output()
+	if err != nil {
+		return false
+	}
+	return versionAtLeast(string(out), major, minor)
+}
+
+var gitVersion = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// versionAtLeast parses git version output such as "git version 2.39.5".
+func versionAtLeast(out string, major, minor int) bool {
+	m := gitVersion.FindStringSubmatch(out)
+	if m == nil {
+		return false
+	}
+	gotMajor, _ := strconv.Atoi(m[1])
+	gotMinor, _ := strconv.Atoi(m[2])
+	return gotMajor > major || gotMajor == major && gotMinor >= minor
+}
+
+// readObjectStats runs git count-objects -v against a .git directory.
+func readObjectStats(gitDir string) (ObjectStats, error) {
+	out, err := exec.Command("git", "--git-dir="+gitDir, "count-objects", "-v").Output()
+	if err != nil {
+		return ObjectStats{}, err
+	}
+	return parseCountObjects(string(out)), nil
+}
+
+// parseCountObjects parses git count-objects -v output; sizes are reported in KiB.
+func parseCountObjects(out string) ObjectStats {
+	var s ObjectStats
+	for _, line := range strings.Split(out, "\n") {
+		key, val, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		switch key {
+		case "count":
+			s.Loose = int(n)
+		case "size":
+			s.LooseBytes = n * 1024
+		case "in-pack":
+			s.InPack = int(n)
+		case "packs":
+			s.Packs = int(n)
+		case "size-pack":
+			s.PackBytes = n * 1024
+		case "prune-packable":
+			s.Prunable = int(n)
+		case "garbage":
+			s.Garbage = int(n)
+		case "size-garbage":
+			s.GarbageSize = n * 1024
+		}
+	}
+	return s
+}
+
+// runSteps runs each git command in the repository, writing their output to w.
+// Every step runs even if an earlier one fails, until ctx is done; the step
+// names and any errors are returned.
+func runSteps(ctx context.Context, repoPath string, cmds [][]string, w io.Writer) ([]string, error) {
+	var steps []string
+	var errs []error
+	for _, args := range cmds {
+		if ctx.Err() != nil {
+			break
+		}
+		step := "git " + strings.Join(args, " ")
+		steps = append(steps, step)
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = repoPath
+		cmd.Stdout = w
+		cmd.Stderr = w
+		cmd.WaitDelay = 5 * time.Second // Don't wait forever on children that keep the output open
+		if err := cmd.Run(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", step, err))
+		}
+	}
+	return steps, errors.Join(errs...)
+}
+
+// cleanRepo runs the repository's strategy within the policy's timeout and
+// re-measures its .git directory straight away. The git output is kept when
+// the strategy fails or with --verbose.
+func cleanRepo(f *Finding, p gcPolicy) {
+	ctx := context.Background()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	var out bytes.Buffer
+	steps, err := runSteps(ctx, f.RepoPath, p.commands(f.Action, f.Audit), &out)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", p.timeout, err)
+	}
+	f.GC = &GCResult{OK: err == nil, Steps: steps}
+	if err != nil {
+		f.GC.Error = err.Error()
+	}
+	if err != nil || *flagVerbose {
+		f.GC.Output = out.String()
+	}
+
+	if after := measureGitDirs([]string{f.Path}); len(after) == 1 {
+		f.GC.AfterBytes, f.GC.AfterItems = after[0].SizeBytes, after[0].Items
+	}
+	f.GC.SavedBytes = f.SizeBytes - f.GC.AfterBytes
+}
+
+// planActions sets the strategy for each finding: the first matching per-repo
+// override, else the policy's strategy. With StrategyAuto the strategy is
+// chosen from git count-objects -v and healthy repositories are skipped.
+// With --slim every repository not overridden to StrategyNever is slimmed
+// instead, unless the safety audit finds a reason not to.
+// Repositories below the policy's minimum size are skipped too.
+func planActions(findings []Finding, overrides []RepoOverride, p gcPolicy) {
+	now := time.Now()
+	for i := range findings {
+		f := &findings[i]
+		f.Action, f.Reason, f.Skip, f.Objects, f.Audit = p.strategy, "", "", nil, nil
+		for _, o := range overrides {
+			if cachecore.MatchPath(o.Path, f.RepoPath) {
+				f.Action = o.Strategy
+				break
+			}
+		}
+		if p.slim != "" && f.Action != StrategyNever {
+			f.Action = StrategySlim
+		}
+		switch {
+		case f.Action == StrategyNever:
+			f.Skip = "strategy: never"
+			continue
+		case f.SizeBytes < p.minSize:
+			f.Skip = "below minimum repo size " + human(p.minSize)
+			continue
+		case f.Action == StrategySlim:
+			f.Audit = readAudit(*f)
+			f.Skip = p.slimSkip(*f, now)
+			continue
+		case f.Action != StrategyAuto:
+			continue
+		}
+
+		stats, err := readObjectStats(f.Path)
+		if err != nil {
+			// Let git gc report what is wrong with the repository
+			f.Action, f.Reason = StrategyGC, "git count-objects failed"
+			continue
+		}
+		f.Objects = &stats
+		if f.Action, f.Reason = p.choose(stats); f.Action == "" {
+			f.Action, f.Skip = StrategyNever, fmt.Sprintf("healthy: %d loose objects, %d packs", stats.Loose, stats.Packs)
+		}
+	}
+}
+
+// cleanRepos runs the planned strategy in each repository that is not skipped,
+// up to --jobs at a time, and records the outcome on each finding and in the
+// report totals. A line per repository and a progress line are written to w.
+func cleanRepos(rep *Report, p gcPolicy, w io.Writer) {
+	var findings []*Finding
+	for i := range rep.Findings {
+		if rep.Findings[i].Skip == "" {
+			findings = append(findings, &rep.Findings[i])
+		}
+	}
+	jobs := *flagJobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	fmt.Fprintf(w, "\nRunning maintenance in %d repositories (%d at a time)...\n", len(findings), jobs)
+
+	var (
+		mu      sync.Mutex
+		done    int
+		running int
+		saved   int64
+	)
+	// Callers hold mu
+	status := func() {
+		fmt.Fprintf(w, "\r%d/%d done, %d running, %s saved ", done, len(findings), running, human(saved))
+	}
+	clearStatus := func() { fmt.Fprintf(w, "\r%*s\r", 60, "") }
+
+	forEachJob(len(findings), func(i int) {
+		f := findings[i]
+		mu.Lock()
+		running++
+		status()
+		mu.Unlock()
+
+		cleanRepo(f, p)
+
+		mu.Lock()
+		defer mu.Unlock()
+		running--
+		done++
+		clearStatus()
+		if f.GC.OK {
+			rep.Cleaned++
+			saved += f.GC.SavedBytes
+			outcome := "ok"
+			if f.GC.SavedBytes < 0 {
+				rep.Grew++
+				outcome = "GREW"
+			}
+			fmt.Fprintf(w, "  %-6s %s (%s): %s -> %s\n", outcome, f.RepoPath, f.Action, human(f.SizeBytes), human(f.GC.AfterBytes))
+		} else {
+			rep.Failed++
+			fmt.Fprintf(w, "  FAILED %s (%s): %s\n", f.RepoPath, f.Action, f.GC.Error)
+		}
+		if *flagVerbose && f.GC.Output != "" {
+			for _, line := range strings.Split(strings.TrimRight(f.GC.Output, "\n"), "\n") {
+				fmt.Fprintf(w, "         | %s\n", line)
+			}
+		}
+		status()
+	})
+	fmt.Fprintln(w)
+
+	rep.TotalAfter = rep.TotalBefore
+	for _, f := range findings {
+		rep.TotalAfter -= f.GC.SavedBytes
+	}
+	rep.Saved = rep.TotalBefore - rep.TotalAfter
+}
+
+// repoLabel is the repository path as shown in tables, noting bare
+// repositories, submodules and linked worktrees.
+func repoLabel(f Finding) string {
+	label := f.RepoPath
+	if f.Kind == KindBare || f.Kind == KindSubmodule {
+		label += " (" + f.Kind + ")"
+	}
+	switch n := len(f.Worktrees); n {
+	case 0:
+	case 1:
+		label += " (+1 worktree)"
+	default:
+		label += fmt.Sprintf(" (+%d worktrees)", n)
+	}
+	return label
 }
 
 // displayResults displays findings in a table
@@ -154,7 +1402,7 @@ func displayResults(findings []Finding, total int64) {
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.Header("Repository Path", ".git Size", "Items")
+	table.Header("Repository Path", ".git Size", "Items", "Action")
 
 	// Sort by size (largest first)
 	sortedFindings := make([]Finding, len(findings))
@@ -165,13 +1413,94 @@ func displayResults(findings []Finding, total int64) {
 
 	var totalItems int
 	for _, f := range sortedFindings {
-		if err := table.Append(f.RepoPath, human(f.SizeBytes), fmt.Sprintf("%d", f.Items)); err != nil {
+		action := f.Action
+		if f.Skip != "" {
+			action = "skip"
+		}
+		if err := table.Append(repoLabel(f), human(f.SizeBytes), fmt.Sprintf("%d", f.Items), action); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: error appending to table: %v\n", err)
 		}
 		totalItems += f.Items
 	}
 
-	table.Footer("TOTAL", human(total), fmt.Sprintf("%d", totalItems))
+	table.Footer("TOTAL", human(total), fmt.Sprintf("%d", totalItems), "")
+	if err := table.Render(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+	}
+}
+
+// Sort orders for --sort.
+var sortOrders = []string{"saved", "before", "after", "path"}
+
+// percentSaved formats saved as a percentage of before, or "n/a" when there
+// was nothing to begin with.
+func percentSaved(saved, before int64) string {
+	if before <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f%%", float64(saved)/float64(before)*100)
+}
+
+// cleanStatus describes the outcome of --clean for one repository.
+func cleanStatus(f Finding) string {
+	switch {
+	case f.GC == nil:
+		return "skipped"
+	case !f.GC.OK:
+		return "FAILED"
+	case f.GC.SavedBytes < 0:
+		return "GREW"
+	}
+	return "ok"
+}
+
+// sortSavings orders findings for displaySavings: by bytes saved (largest
+// first), by size before or after (largest first), or by path. Skipped
+// repositories count as unchanged.
+func sortSavings(findings []Finding, by string) []Finding {
+	after := func(f Finding) int64 {
+		if f.GC == nil {
+			return f.SizeBytes
+		}
+		return f.GC.AfterBytes
+	}
+	sorted := make([]Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		switch by {
+		case "before":
+			return a.SizeBytes > b.SizeBytes
+		case "after":
+			return after(a) > after(b)
+		case "path":
+			return a.RepoPath < b.RepoPath
+		}
+		return a.SizeBytes-after(a) > b.SizeBytes-after(b)
+	})
+	return sorted
+}
+
+// displaySavings shows each repository's size before and after --clean, the
+// bytes saved and whether it was skipped, grew or failed.
+func displaySavings(findings []Finding, sortBy string) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Repository Path", "Action", "Before", "After", "Saved", "Status")
+
+	var before, after int64
+	for _, f := range sortSavings(findings, sortBy) {
+		a := f.SizeBytes
+		if f.GC != nil {
+			a = f.GC.AfterBytes
+		}
+		before += f.SizeBytes
+		after += a
+		if err := table.Append(repoLabel(f), f.Action, human(f.SizeBytes), human(a), cachecore.SignedHuman(f.SizeBytes-a), cleanStatus(f)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: error appending to table: %v\n", err)
+		}
+	}
+
+	table.Footer("TOTAL", "", human(before), human(after), cachecore.SignedHuman(before-after), percentSaved(before-after, before))
 	if err := table.Render(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
 	}
@@ -183,19 +1512,7 @@ func expandScanPath(scanPath string) (string, error) {
 	if scanPath == "" {
 		return "", fmt.Errorf("scan path is required")
 	}
-	if strings.HasPrefix(scanPath, "~") {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			if len(scanPath) > 1 && scanPath[1] == filepath.Separator {
-				scanPath = filepath.Join(home, scanPath[2:])
-			} else if len(scanPath) == 1 {
-				scanPath = home
-			} else {
-				scanPath = filepath.Join(home, scanPath[1:])
-			}
-		}
-	}
-	scanPath = os.ExpandEnv(scanPath)
+	scanPath = cachecore.Expand(scanPath)
 	abs, err := filepath.Abs(scanPath)
 	if err != nil {
 		return "", fmt.Errorf("invalid scan path: %w", err)
@@ -206,6 +1523,67 @@ func expandScanPath(scanPath string) (string, error) {
 	return abs, nil
 }
 
+func writeStarterConfig(path string, force bool) error {
+	starter := Config{
+		Version: 1,
+		Options: Options{
+			ScanPaths:    []string{"~/src"},
+			Exclude:      []string{"node_modules", "**/vendor/**", "/mnt/*", "/Volumes/*"},
+			MinRepoSize:  "10MB",
+			Strategy:     StrategyAuto,
+			Thresholds:   Thresholds{LooseObjects: 1000, Packs: 20, LargeRepo: "2GB"},
+			Repack:       Repack{Window: 250, Depth: 50},
+			Housekeeping: []string{"worktrees", "reflog"},
+			ReflogExpire: "90.days.ago",
+		},
+		Repos: []RepoOverride{
+			{Path: "~/src/mirrors/*", Strategy: StrategyAggressive},
+		},
+	}
+	return cachecore.WriteConfig(path, force, starter)
+}
+
+// loadConfig reads the config at path. A missing config is not an error, so
+// that git-cleaner keeps working with only --scan.
+func loadConfig(path string) (*Config, error) {
+	cfg, err := cachecore.LoadConfig[Config](path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	return cfg, err
+}
+
+// scanPaths returns the roots to scan: --scan if given, otherwise the
+// config's scanPaths, each expanded and checked to exist.
+func scanPaths(flagValue string, cfg *Config) ([]string, error) {
+	roots := cfg.Options.ScanPaths
+	if flagValue != "" {
+		roots = []string{flagValue}
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("scan path is required")
+	}
+	var paths []string
+	for _, r := range roots {
+		p, err := expandScanPath(r)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// printJSON writes the report to stdout.
+func printJSON(rep Report) {
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		fmt.Println("json error:", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(b))
+}
+
 func main() {
 	if checkVersionFlag() {
 		fmt.Printf("version %s, commit %s, built at %s\n", version, commit, date)
@@ -214,75 +1592,206 @@ func main() {
 
 	flag.Parse()
 
-	scanPath, err := expandScanPath(*flagScan)
+	if *flagInit {
+		if err := writeStarterConfig(*flagConfig, *flagForce); err != nil {
+			fmt.Println("init error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Starter config written to:", *flagConfig)
+		return
+	}
+
+	cfg, err := loadConfig(*flagConfig)
+	if err != nil {
+		fmt.Println("config error:", err)
+		fmt.Println("Tip: run with --init to create a starter config")
+		os.Exit(1)
+	}
+
+	roots, err := scanPaths(*flagScan, cfg)
 	if err != nil {
 		if err.Error() == "scan path is required" {
-			fmt.Println("Error: --scan flag is required")
-			fmt.Println("Usage: git-cleaner --scan <directory> [--clean]")
+			fmt.Println("Error: --scan flag or scanPaths in the config is required")
+			fmt.Println("Usage: git-cleaner [--scan <directory>] [--clean] [--json]")
+			fmt.Println("Tip: run with --init to create a starter config")
 		} else {
 			fmt.Printf("Error: %v\n", err)
 		}
 		os.Exit(1)
 	}
 
-	fmt.Printf("Scanning %s for .git directories...\n", scanPath)
+	// Determine minimum repository size and maintenance strategy
+	policy, err := newPolicy(cfg, *flagMinSize, *flagStrat)
+	if err != nil {
+		fmt.Println("config error:", err)
+		os.Exit(1)
+	}
+	policy.timeout = *flagTimeout
+	if *flagAudit && (*flagClean || *flagSlim != "") {
+		fmt.Println("Error: --audit only reports; it cannot be combined with --clean or --slim")
+		os.Exit(1)
+	}
+	if *flagSlim != "" {
+		if !slices.Contains(slimModes, *flagSlim) {
+			fmt.Printf("Error: unknown --slim %q (use one of %s)\n", *flagSlim, strings.Join(slimModes, ", "))
+			os.Exit(1)
+		}
+		if *flagSlimDepth < 1 {
+			fmt.Println("Error: --slim-depth must be at least 1")
+			os.Exit(1)
+		}
+		if policy.inactiveFor, err = cachecore.ParseAge(*flagInactive); err != nil {
+			fmt.Println("Error: --inactive-for:", err)
+			os.Exit(1)
+		}
+		policy.slim, policy.slimDepth = *flagSlim, *flagSlimDepth
+	}
+	if !slices.Contains(sortOrders, *flagSort) {
+		fmt.Printf("Error: unknown --sort %q (use one of %s)\n", *flagSort, strings.Join(sortOrders, ", "))
+		os.Exit(1)
+	}
 
-	// Initial scan
-	findings := scanDirectory(scanPath)
+	if !*flagNoIndex {
+		sizeIndex = cachecore.LoadIndex(cachecore.DefaultIndexPath("git-cleaner"))
+		defer func() {
+			if err := sizeIndex.Save(); err != nil {
+				fmt.Fprintln(os.Stderr, "size index error:", err)
+			}
+		}()
+	}
 
-	var totalBefore int64
-	for _, f := range findings {
-		totalBefore += f.SizeBytes
+	exclude, err := cachecore.NewExcluder(cachecore.DefaultIgnorePath())
+	if err != nil {
+		fmt.Println("ignore file error:", err)
+		os.Exit(1)
 	}
+	exclude.Add("config", cfg.Options.Exclude...)
+	exclude.Add("--exclude", *flagExclude...)
+	sizeExclude = exclude
 
-	if len(findings) == 0 {
-		fmt.Println("No .git directories found.")
-		return
+	// With --json, stdout carries only the report
+	var progress io.Writer = os.Stdout
+	if *flagJSON {
+		progress = os.Stderr
 	}
 
-	// Display initial results
-	fmt.Printf("\nFound %d repositories:\n\n", len(findings))
-	displayResults(findings, totalBefore)
+	rep := Report{
+		Envelope:    cachecore.NewEnvelope(!*flagClean),
+		ScanPaths:   roots,
+		MinRepoSize: policy.minSize,
+		Strategy:    policy.strategy,
+		Slim:        policy.slim,
+		Findings:    []Finding{},
+		Warnings:    []string{},
+	}
+	rep.SizeMode = newSizer().Mode()
 
-	// Clean if requested
-	if *flagClean {
-		fmt.Printf("\nRunning git gc in %d repositories...\n", len(findings))
-		var errors []string
-		var cleanedCount int
+	for _, root := range roots {
+		fmt.Fprintf(progress, "Scanning %s for .git directories...\n", root)
+	}
 
-		for _, f := range findings {
-			fmt.Printf("  Cleaning %s...\n", f.RepoPath)
-			if err := runGitGC(f.RepoPath); err != nil {
-				errors = append(errors, fmt.Sprintf("%s: %v", f.RepoPath, err))
-				continue
-			}
-			cleanedCount++
+	// Initial scan
+	var boundary *cachecore.Boundary
+	if *flagOneFS {
+		boundary = cachecore.NewBoundary(roots...)
+	}
+	rep.Findings = scanRoots(roots, exclude, boundary)
+	rep.Excluded = exclude.Excluded()
+	for _, m := range boundary.Skipped() {
+		w := fmt.Sprintf("skipped %s: on another filesystem (use --one-file-system=false to scan it)", m)
+		rep.Warnings = append(rep.Warnings, w)
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+	if summary := cachecore.ExcludedSummary(rep.Excluded); summary != "" {
+		fmt.Fprintln(progress, summary)
+	}
+	if *flagAudit {
+		fmt.Fprintln(progress, "Auditing repositories...")
+		audit(rep.Findings)
+		if *flagJSON {
+			printJSON(rep)
+		} else if len(rep.Findings) == 0 {
+			fmt.Println("No .git directories found.")
+		} else {
+			fmt.Printf("\nAudited %d repositories:\n\n", len(rep.Findings))
+			displayAudit(rep.Findings)
 		}
+		return
+	}
+	planActions(rep.Findings, cfg.Repos, policy)
+	for _, f := range rep.Findings {
+		rep.TotalBefore += f.SizeBytes
+	}
+	if *flagBreak {
+		fmt.Fprintln(progress, "Breaking down repositories...")
+		breakDown(rep.Findings)
+	}
 
-		if len(errors) > 0 {
-			fmt.Println("\nErrors:")
-			for _, e := range errors {
-				fmt.Printf("  - %s\n", e)
+	if *flagClean && len(rep.Findings) > 0 {
+		if !*flagJSON {
+			fmt.Printf("\nFound %d repositories:\n\n", len(rep.Findings))
+			displayResults(rep.Findings, rep.TotalBefore)
+			if *flagBreak {
+				fmt.Printf("\nBreakdown:\n\n")
+				displayBreakdown(rep.Findings)
 			}
 		}
+		cleanRepos(&rep, policy, progress)
+	}
+
+	if *flagJSON {
+		printJSON(rep)
+		return
+	}
 
-		// Rescan after cleanup
-		fmt.Println("\nRescanning after cleanup...")
-		time.Sleep(100 * time.Millisecond) // Brief pause to ensure file system updates
-		afterFindings := scanDirectory(scanPath)
+	if len(rep.Findings) == 0 {
+		fmt.Println("No .git directories found.")
+		return
+	}
 
-		var totalAfter int64
-		for _, f := range afterFindings {
-			totalAfter += f.SizeBytes
+	if !*flagClean {
+		fmt.Printf("\nFound %d repositories:\n\n", len(rep.Findings))
+		displayResults(rep.Findings, rep.TotalBefore)
+		if *flagBreak {
+			fmt.Printf("\nBreakdown:\n\n")
+			displayBreakdown(rep.Findings)
 		}
+		return
+	}
+
+	var errors, skipped []string
+	for _, f := range rep.Findings {
+		switch {
+		case f.GC == nil:
+			skipped = append(skipped, fmt.Sprintf("%s: %s", f.RepoPath, f.Skip))
+		case f.GC.Error != "":
+			e := fmt.Sprintf("%s: %s", f.RepoPath, f.GC.Error)
+			if out := strings.TrimRight(f.GC.Output, "\n"); out != "" && !*flagVerbose {
+				e += "\n      " + strings.ReplaceAll(out, "\n", "\n      ")
+			}
+			errors = append(errors, e)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Println("\nSkipped:")
+		for _, s := range skipped {
+			fmt.Printf("  - %s\n", s)
+		}
+	}
+	if len(errors) > 0 {
+		fmt.Println("\nErrors:")
+		for _, e := range errors {
+			fmt.Printf("  - %s\n", e)
+		}
+	}
 
-		diskSavings := totalBefore - totalAfter
-		fmt.Printf("\nResults after cleanup:\n\n")
-		displayResults(afterFindings, totalAfter)
+	fmt.Printf("\nResults after cleanup:\n\n")
+	displaySavings(rep.Findings, *flagSort)
 
-		fmt.Printf("\nDisk savings: %s (%.2f%%)\n",
-			human(diskSavings),
-			float64(diskSavings)/float64(totalBefore)*100)
-		fmt.Printf("Cleaned %d repositories\n", cleanedCount)
+	fmt.Printf("\nDisk savings: %s (%s)\n", human(rep.Saved), percentSaved(rep.Saved, rep.TotalBefore))
+	fmt.Printf("Cleaned %d repositories", rep.Cleaned)
+	if rep.Grew > 0 || rep.Failed > 0 {
+		fmt.Printf(" (%d grew, %d failed)", rep.Grew, rep.Failed)
 	}
+	fmt.Println()
 }
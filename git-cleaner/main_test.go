@@ -2,11 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"cachecore"
 )
 
 func TestCheckVersionFlag(t *testing.T) {
@@ -164,7 +170,7 @@ func TestScanDirectory(t *testing.T) {
 	}
 
 	// Test scanning
-	findings := scanDirectory(root)
+	findings := scanDirectory(root, nil, nil)
 	if len(findings) != 3 {
 		t.Fatalf("expected 3 findings, got %d", len(findings))
 	}
@@ -210,7 +216,7 @@ func TestScanDirectoryInspectPathError(t *testing.T) {
 	}
 	defer func() { _ = os.Chmod(objectsDir, 0o755) }()
 
-	findings := scanDirectory(root)
+	findings := scanDirectory(root, nil, nil)
 	// Should still find the repo (inspectPath may return partial or error)
 	if len(findings) != 1 {
 		t.Fatalf("expected 1 finding, got %d", len(findings))
@@ -228,7 +234,7 @@ func TestScanDirectoryNoGitDirs(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	findings := scanDirectory(root)
+	findings := scanDirectory(root, nil, nil)
 	if len(findings) != 0 {
 		t.Fatalf("expected 0 findings, got %d", len(findings))
 	}
@@ -257,7 +263,7 @@ func TestScanDirectoryNestedGit(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	findings := scanDirectory(root)
+	findings := scanDirectory(root, nil, nil)
 	if len(findings) != 1 {
 		t.Fatalf("expected 1 finding (nested .git should be skipped), got %d", len(findings))
 	}
@@ -266,7 +272,7 @@ func TestScanDirectoryNestedGit(t *testing.T) {
 	}
 }
 
-func TestRunGitGC(t *testing.T) {
+func TestRunSteps(t *testing.T) {
 	// Create a real git repo and run gc
 	dir := t.TempDir()
 	if err := exec.Command("git", "init", dir).Run(); err != nil {
@@ -283,8 +289,86 @@ func TestRunGitGC(t *testing.T) {
 		t.Skip("git commit failed:", err)
 	}
 
-	if err := runGitGC(dir); err != nil {
-		t.Fatalf("runGitGC failed: %v", err)
+	p, err := newPolicy(&Config{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, strategy := range []string{StrategyMaintenance, StrategyGC, StrategyRepack, StrategyAggressive} {
+		steps, err := runSteps(context.Background(), dir, p.commands(strategy, nil), io.Discard)
+		if err != nil || len(steps) != 1 {
+			t.Fatalf("%s: steps=%v err=%v", strategy, steps, err)
+		}
+	}
+
+	// A failing step is reported but does not stop the ones after it
+	steps, err := runSteps(context.Background(), dir, [][]string{{"remote", "prune", "origin"}, {"gc"}}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "git remote prune origin") || strings.Contains(err.Error(), "git gc") {
+		t.Fatalf("expected only the remote prune to fail, got %v", err)
+	}
+	if len(steps) != 2 || steps[1] != "git gc" {
+		t.Fatalf("unexpected steps: %v", steps)
+	}
+}
+
+func TestParseCountObjects(t *testing.T) {
+	out := "count: 12\nsize: 48\nin-pack: 3000\npacks: 4\nsize-pack: 2048\nprune-packable: 2\ngarbage: 1\nsize-garbage: 8\n"
+	want := ObjectStats{Loose: 12, LooseBytes: 48 << 10, InPack: 3000, Packs: 4, PackBytes: 2 << 20, Prunable: 2, Garbage: 1, GarbageSize: 8 << 10}
+	if got := parseCountObjects(out); got != want {
+		t.Fatalf("parseCountObjects = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	p, err := newPolicy(&Config{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.strategy != StrategyAuto || p.looseObjects != 1000 || p.packs != 20 || p.largeRepo != 2<<30 || p.window != 250 || p.depth != 50 {
+		t.Fatalf("unexpected defaults: %+v", p)
+	}
+
+	cfg := &Config{Options: Options{Strategy: StrategyRepack, MinRepoSize: "1MB", Repack: Repack{Window: 10, Depth: 20}, Housekeeping: []string{"reflog"}, ReflogExpire: "now"}}
+	p, err = newPolicy(cfg, "5MB", StrategyGC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.strategy != StrategyGC || p.minSize != 5<<20 {
+		t.Fatalf("flags should override config: %+v", p)
+	}
+	cmds := p.commands(StrategyRepack, nil)
+	if len(cmds) != 2 || strings.Join(cmds[0], " ") != "reflog expire --expire=now --all" || strings.Join(cmds[1], " ") != "repack -adf --window=10 --depth=20" {
+		t.Fatalf("unexpected commands: %v", cmds)
+	}
+
+	for _, bad := range []*Config{
+		{Options: Options{Strategy: "fast"}},
+		{Options: Options{Housekeeping: []string{"tags"}}},
+		{Options: Options{Thresholds: Thresholds{LargeRepo: "big"}}},
+		{Repos: []RepoOverride{{Path: "/src/*", Strategy: "gc --aggressive"}}},
+	} {
+		if _, err := newPolicy(bad, "", ""); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestPolicyChoose(t *testing.T) {
+	p, _ := newPolicy(&Config{}, "", "")
+	tests := []struct {
+		stats ObjectStats
+		want  string
+	}{
+		{ObjectStats{Loose: 10, Packs: 2}, ""},
+		{ObjectStats{Loose: 5000, Packs: 1}, StrategyGC},
+		{ObjectStats{Garbage: 1, Packs: 1}, StrategyGC},
+		{ObjectStats{Packs: 40, PackBytes: 100 << 20}, StrategyRepack},
+		{ObjectStats{Packs: 40, PackBytes: 3 << 30}, StrategyMaintenance},
+	}
+	for _, tt := range tests {
+		got, reason := p.choose(tt.stats)
+		if got != tt.want || (got != "" && reason == "") {
+			t.Fatalf("choose(%+v) = %q (%q), want %q", tt.stats, got, reason, tt.want)
+		}
 	}
 }
 
@@ -295,8 +379,8 @@ func TestDisplayResults(t *testing.T) {
 	defer func() { os.Stdout = old }()
 
 	findings := []Finding{
-		{Path: "/repo1/.git", RepoPath: "/repo1", SizeBytes: 1024, Items: 10},
-		{Path: "/repo2/.git", RepoPath: "/repo2", SizeBytes: 2048, Items: 5},
+		{Finding: cachecore.Finding{Path: "/repo1/.git", SizeBytes: 1024, Items: 10}, RepoPath: "/repo1"},
+		{Finding: cachecore.Finding{Path: "/repo2/.git", SizeBytes: 2048, Items: 5}, RepoPath: "/repo2"},
 	}
 	displayResults(findings, 3072)
 
@@ -406,3 +490,716 @@ func TestExpandScanPath(t *testing.T) {
 		t.Fatalf("expandScanPath($TEST_SCAN_DIR) = %q, want %q", got, abs)
 	}
 }
+
+func TestMeasureGitDirs(t *testing.T) {
+	root := t.TempDir()
+	gitDir := filepath.Join(root, "repo", ".git")
+	if err := os.MkdirAll(gitDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	findings := measureGitDirs([]string{gitDir, filepath.Join(root, "gone", ".git")})
+	if len(findings) != 1 {
+		t.Fatalf("expected the missing .git to be skipped, got %d findings", len(findings))
+	}
+	if f := findings[0]; f.RepoPath != filepath.Join(root, "repo") || f.SizeBytes != 21 || f.Items != 1 {
+		t.Fatalf("unexpected finding: %+v", f)
+	}
+
+	// Excluded directories inside the git dir are not counted
+	cache := filepath.Join(gitDir, "lfs", "cache")
+	if err := os.MkdirAll(cache, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cache, "blob"), make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := sizeExclude
+	defer func() { sizeExclude = old }()
+	sizeExclude, _ = cachecore.NewExcluder("")
+	sizeExclude.Add("--exclude", "cache")
+	if f := measureGitDirs([]string{gitDir})[0]; f.SizeBytes != 21 || f.Items != 1 {
+		t.Fatalf("expected lfs/cache to be excluded, got %+v", f)
+	}
+}
+
+func TestCleanReposReport(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	if err := exec.Command("git", "init", repo).Run(); err != nil {
+		t.Skip("git not available or init failed:", err)
+	}
+	// A .git directory git cannot operate on, so gc fails
+	broken := filepath.Join(root, "broken", ".git")
+	if err := os.MkdirAll(broken, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(broken, "HEAD"), []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rep := Report{Findings: scanDirectory(root, nil, nil)}
+	for _, f := range rep.Findings {
+		rep.TotalBefore += f.SizeBytes
+	}
+	p, _ := newPolicy(&Config{}, "", StrategyGC)
+	planActions(rep.Findings, nil, p)
+	var progress bytes.Buffer
+	cleanRepos(&rep, p, &progress)
+
+	if out := progress.String(); !strings.Contains(out, "2/2 done, 0 running") || !strings.Contains(out, "FAILED "+filepath.Dir(broken)) {
+		t.Fatalf("unexpected progress output: %q", out)
+	}
+
+	if rep.Cleaned != 1 || rep.Failed != 1 {
+		t.Fatalf("cleaned=%d failed=%d, want 1 and 1", rep.Cleaned, rep.Failed)
+	}
+	var total int64
+	for _, f := range rep.Findings {
+		if f.GC == nil {
+			t.Fatalf("%s: missing gc result", f.RepoPath)
+		}
+		if f.RepoPath == filepath.Dir(broken) && (f.GC.OK || f.GC.Error == "" || f.GC.Output == "") {
+			t.Fatalf("expected gc failure with its output for broken repo, got %+v", f.GC)
+		}
+		if f.RepoPath == repo && f.GC.Output != "" {
+			t.Fatalf("output should only be kept on failure without --verbose, got %q", f.GC.Output)
+		}
+		if f.GC.SavedBytes != f.SizeBytes-f.GC.AfterBytes {
+			t.Fatalf("%s: saved %d != before %d - after %d", f.RepoPath, f.GC.SavedBytes, f.SizeBytes, f.GC.AfterBytes)
+		}
+		total += f.GC.AfterBytes
+	}
+	if rep.TotalAfter != total || rep.Saved != rep.TotalBefore-rep.TotalAfter {
+		t.Fatalf("unexpected totals: %+v", rep)
+	}
+
+	b, err := json.Marshal(rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"hostname"`, `"scan_paths"`, `"total_before_bytes"`, `"saved_bytes"`, `"gc":{`, `"after_bytes"`, `"error"`} {
+		if !strings.Contains(string(b), key) {
+			t.Fatalf("expected %s in JSON report: %s", key, b)
+		}
+	}
+}
+
+func TestWriteAndLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "git-cleaner", "config.yaml")
+
+	cfg, err := loadConfig(path)
+	if err != nil || len(cfg.Options.ScanPaths) != 0 {
+		t.Fatalf("missing config should load empty, got %+v, %v", cfg, err)
+	}
+
+	if err := writeStarterConfig(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeStarterConfig(path, false); err == nil {
+		t.Fatal("expected error overwriting config without --force")
+	}
+	if err := writeStarterConfig(path, true); err != nil {
+		t.Fatal(err)
+	}
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("expected one backup, got %v", backups)
+	}
+
+	cfg, err = loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Options.ScanPaths) == 0 || cfg.Options.MinRepoSize == "" || len(cfg.Repos) == 0 {
+		t.Fatalf("starter config did not round-trip: %+v", cfg)
+	}
+
+	if err := os.WriteFile(path, []byte("options: ["), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Fatal("expected error for invalid YAML")
+	}
+}
+
+func TestScanPaths(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	cfg := &Config{Options: Options{ScanPaths: []string{a, b}}}
+
+	got, err := scanPaths("", cfg)
+	if err != nil || len(got) != 2 || got[0] != a || got[1] != b {
+		t.Fatalf("scanPaths from config = %v, %v", got, err)
+	}
+	got, err = scanPaths(a, cfg)
+	if err != nil || len(got) != 1 || got[0] != a {
+		t.Fatalf("--scan should replace the config roots, got %v, %v", got, err)
+	}
+	if _, err := scanPaths("", &Config{}); err == nil || err.Error() != "scan path is required" {
+		t.Fatalf("expected 'scan path is required', got %v", err)
+	}
+	cfg.Options.ScanPaths = append(cfg.Options.ScanPaths, filepath.Join(a, "missing"))
+	if _, err := scanPaths("", cfg); err == nil {
+		t.Fatal("expected error for missing scan root")
+	}
+}
+
+func TestScanRootsExcludeAndOverlap(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"app/.git", "app/vendor/lib/.git", "app/scratch/.git", "mnt/nas/.git", "other/.git"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "app", cachecore.IgnoreFileName), []byte("scratch/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second root overlaps the first and must not count other/.git twice
+	exclude, _ := cachecore.NewExcluder("")
+	exclude.Add("config", "**/vendor/**", filepath.Join(root, "mnt", "*"))
+	findings := scanRoots([]string{root, filepath.Join(root, "other")}, exclude, nil)
+	got := map[string]bool{}
+	for _, f := range findings {
+		got[f.RepoPath] = true
+	}
+	if len(findings) != 2 || !got[filepath.Join(root, "app")] || !got[filepath.Join(root, "other")] {
+		t.Fatalf("unexpected findings: %v", got)
+	}
+	if excluded := exclude.Excluded(); len(excluded) != 3 {
+		t.Fatalf("expected vendor, scratch and mnt/nas to be excluded, got %+v", excluded)
+	}
+}
+
+func TestPlanActions(t *testing.T) {
+	findings := []Finding{
+		{Finding: cachecore.Finding{SizeBytes: 100 << 20}, RepoPath: "/src/app"},
+		{Finding: cachecore.Finding{SizeBytes: 1 << 20}, RepoPath: "/src/tiny"},
+		{Finding: cachecore.Finding{SizeBytes: 500 << 20}, RepoPath: "/src/mirrors/linux"},
+		{Finding: cachecore.Finding{SizeBytes: 500 << 20}, RepoPath: "/src/keep"},
+	}
+	overrides := []RepoOverride{
+		{Path: "/src/mirrors/*", Strategy: StrategyAggressive},
+		{Path: "/src/keep", Strategy: StrategyNever},
+		{Path: "/src/*", Strategy: StrategyRepack}, // shadowed by the earlier matches
+	}
+	p, _ := newPolicy(&Config{}, "10MB", StrategyGC)
+	planActions(findings, overrides[:2], p)
+
+	want := []struct{ action, skip string }{
+		{StrategyGC, ""},
+		{StrategyGC, "below minimum repo size 10.00 MB"},
+		{StrategyAggressive, ""},
+		{StrategyNever, "strategy: never"},
+	}
+	for i, w := range want {
+		if findings[i].Action != w.action || findings[i].Skip != w.skip {
+			t.Fatalf("%s: action=%q skip=%q, want %q %q", findings[i].RepoPath, findings[i].Action, findings[i].Skip, w.action, w.skip)
+		}
+	}
+
+	p.minSize = 0
+	planActions(findings, overrides, p)
+	if findings[0].Action != StrategyRepack || findings[3].Action != StrategyNever {
+		t.Fatalf("first matching override should win: %+v", findings)
+	}
+}
+
+func TestPlanActionsAuto(t *testing.T) {
+	repo := t.TempDir()
+	if err := exec.Command("git", "init", repo).Run(); err != nil {
+		t.Skip("git not available or init failed:", err)
+	}
+	for i := 0; i < 3; i++ {
+		cmd := exec.Command("git", "-C", repo, "hash-object", "-w", "--stdin")
+		cmd.Stdin = strings.NewReader(strings.Repeat("x", i+1))
+		if err := cmd.Run(); err != nil {
+			t.Skip("git hash-object failed:", err)
+		}
+	}
+	findings := []Finding{{Finding: cachecore.Finding{Path: filepath.Join(repo, ".git")}, RepoPath: repo}}
+
+	p, _ := newPolicy(&Config{}, "", "")
+	planActions(findings, nil, p)
+	if f := findings[0]; f.Skip == "" || f.Objects == nil || f.Objects.Loose != 3 {
+		t.Fatalf("expected a healthy repo to be skipped, got %+v", f)
+	}
+
+	p.looseObjects = 3
+	planActions(findings, nil, p)
+	if f := findings[0]; f.Skip != "" || f.Action != StrategyGC || f.Reason != "3 loose objects" {
+		t.Fatalf("expected gc for loose objects, got %+v", f)
+	}
+}
+
+func TestCleanRepoTimeout(t *testing.T) {
+	repo := t.TempDir()
+	if err := exec.Command("git", "init", repo).Run(); err != nil {
+		t.Skip("git not available or init failed:", err)
+	}
+	f := &Finding{Finding: cachecore.Finding{Path: filepath.Join(repo, ".git")}, RepoPath: repo, Action: StrategyGC}
+	p, _ := newPolicy(&Config{Options: Options{Housekeeping: []string{"worktrees"}}}, "", "")
+	p.timeout = time.Nanosecond
+
+	cleanRepo(f, p)
+	if f.GC.OK || !strings.Contains(f.GC.Error, "timed out after 1ns") {
+		t.Fatalf("expected a timeout, got %+v", f.GC)
+	}
+	if len(f.GC.Steps) > 1 {
+		t.Fatalf("steps after the timeout should not run, got %v", f.GC.Steps)
+	}
+}
+
+func TestPercentSaved(t *testing.T) {
+	if got := percentSaved(0, 0); got != "n/a" {
+		t.Fatalf("percentSaved(0, 0) = %q", got)
+	}
+	if got := percentSaved(25, 100); got != "25.00%" {
+		t.Fatalf("percentSaved(25, 100) = %q", got)
+	}
+	if got := percentSaved(-10, 100); got != "-10.00%" {
+		t.Fatalf("percentSaved(-10, 100) = %q", got)
+	}
+}
+
+func savingsFindings() []Finding {
+	return []Finding{
+		{Finding: cachecore.Finding{SizeBytes: 1000}, RepoPath: "/src/a", Action: StrategyGC, GC: &GCResult{OK: true, AfterBytes: 900, SavedBytes: 100}},
+		{Finding: cachecore.Finding{SizeBytes: 500}, RepoPath: "/src/b", Action: StrategyRepack, GC: &GCResult{OK: true, AfterBytes: 100, SavedBytes: 400}},
+		{Finding: cachecore.Finding{SizeBytes: 200}, RepoPath: "/src/c", Action: StrategyGC, GC: &GCResult{OK: true, AfterBytes: 260, SavedBytes: -60}},
+		{Finding: cachecore.Finding{SizeBytes: 300}, RepoPath: "/src/d", Action: StrategyGC, GC: &GCResult{Error: "git gc: exit status 128", AfterBytes: 300}},
+		{Finding: cachecore.Finding{SizeBytes: 2000}, RepoPath: "/src/e", Action: StrategyNever, Skip: "strategy: never"},
+	}
+}
+
+func TestSortSavingsAndStatus(t *testing.T) {
+	findings := savingsFindings()
+	tests := []struct {
+		by   string
+		want string
+	}{
+		{"saved", "b a d e c"},
+		{"before", "e a b d c"},
+		{"after", "e a d c b"},
+		{"path", "a b c d e"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, f := range sortSavings(findings, tt.by) {
+			got = append(got, filepath.Base(f.RepoPath))
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Fatalf("sortSavings(%q) = %v, want %s", tt.by, got, tt.want)
+		}
+	}
+
+	var statuses []string
+	for _, f := range findings {
+		statuses = append(statuses, cleanStatus(f))
+	}
+	if got := strings.Join(statuses, " "); got != "ok ok GREW FAILED skipped" {
+		t.Fatalf("cleanStatus = %s", got)
+	}
+}
+
+func TestDisplaySavings(t *testing.T) {
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	defer func() { os.Stdout = old }()
+
+	displaySavings(savingsFindings(), "saved")
+	_ = w.Close()
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+	out := buf.String()
+	for _, want := range []string{"GREW", "FAILED", "SKIPPED", "+400 B", "-60 B", "11.00%"} {
+		if !strings.Contains(strings.ToUpper(out), strings.ToUpper(want)) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "/src/b") > strings.Index(out, "/src/a") {
+		t.Fatalf("expected the largest saving first:\n%s", out)
+	}
+}
+
+// gitRun runs git with a fixed identity, skipping the test if git fails.
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "protocol.file.allow=always"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+// gitOutput is gitRun returning the standard output.
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Skipf("git %v failed: %v", args, err)
+	}
+	return string(out)
+}
+
+func TestScanDirectoryWorktreesSubmodulesBare(t *testing.T) {
+	root := t.TempDir()
+	lib := filepath.Join(root, "lib")
+	app := filepath.Join(root, "app")
+	for _, dir := range []string{lib, app} {
+		gitRun(t, root, "init", "-q", dir)
+		if err := os.WriteFile(filepath.Join(dir, "README"), []byte(dir), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		gitRun(t, dir, "add", "README")
+		gitRun(t, dir, "commit", "-qm", "init")
+	}
+	gitRun(t, app, "submodule", "add", "-q", lib, "lib")
+	gitRun(t, app, "commit", "-qm", "add lib")
+	gitRun(t, app, "worktree", "add", "-q", filepath.Join(root, "app-wt"))
+	gitRun(t, root, "clone", "-q", "--bare", app, filepath.Join(root, "mirror.git"))
+
+	findings := scanDirectory(root, nil, nil)
+	byPath := map[string]Finding{}
+	for _, f := range findings {
+		byPath[f.Path] = f
+	}
+	if len(findings) != 4 {
+		t.Fatalf("expected 4 object stores, got %d: %+v", len(findings), byPath)
+	}
+
+	super := byPath[filepath.Join(app, ".git")]
+	if super.Kind != KindRepo || super.RepoPath != app || len(super.Worktrees) != 1 || super.Worktrees[0] != filepath.Join(root, "app-wt") {
+		t.Fatalf("unexpected superproject finding: %+v", super)
+	}
+	sub := byPath[filepath.Join(app, ".git", "modules", "lib")]
+	if sub.Kind != KindSubmodule || sub.RepoPath != filepath.Join(app, "lib") || sub.SizeBytes == 0 {
+		t.Fatalf("unexpected submodule finding: %+v", sub)
+	}
+	if bare := byPath[filepath.Join(root, "mirror.git")]; bare.Kind != KindBare || bare.RepoPath != filepath.Join(root, "mirror.git") {
+		t.Fatalf("unexpected bare finding: %+v", bare)
+	}
+	if byPath[filepath.Join(lib, ".git")].Kind != KindRepo {
+		t.Fatalf("missing standalone repo: %+v", byPath)
+	}
+
+	// The superproject's size leaves out the submodule's objects
+	whole, err := cachecore.Inspect(filepath.Join(app, ".git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if super.SizeBytes+sub.SizeBytes != whole.SizeBytes {
+		t.Fatalf("superproject %d + submodule %d != whole .git %d", super.SizeBytes, sub.SizeBytes, whole.SizeBytes)
+	}
+
+	// Scanning only the linked worktree still finds the repository that owns its objects
+	wt := scanDirectory(filepath.Join(root, "app-wt"), nil, nil)
+	if len(wt) != 1 || wt[0].Path != filepath.Join(app, ".git") || wt[0].RepoPath != app {
+		t.Fatalf("expected the worktree to resolve to its parent repo, got %+v", wt)
+	}
+
+	// Maintenance runs once per object store
+	rep := Report{Findings: findings}
+	p, _ := newPolicy(&Config{}, "", StrategyGC)
+	planActions(rep.Findings, nil, p)
+	cleanRepos(&rep, p, io.Discard)
+	if rep.Cleaned != 4 || rep.Failed != 0 {
+		for _, f := range rep.Findings {
+			t.Logf("%s: %+v", f.RepoPath, f.GC)
+		}
+		t.Fatalf("cleaned=%d failed=%d, want 4 and 0", rep.Cleaned, rep.Failed)
+	}
+}
+
+func TestParseLFSPrune(t *testing.T) {
+	out := "prune: 6 local objects, 3 retained, done.\nprune: 3 files would be pruned (12 MB)\n"
+	if n, size := parseLFSPrune(out); n != 3 || size != 12<<20 {
+		t.Fatalf("parseLFSPrune = %d, %d", n, size)
+	}
+	if n, size := parseLFSPrune("prune: 2 local objects, 2 retained, done.\n"); n != 0 || size != 0 {
+		t.Fatalf("expected nothing to prune, got %d, %d", n, size)
+	}
+}
+
+func TestReadBreakdown(t *testing.T) {
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(repo, "README"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", "README")
+	gitRun(t, repo, "commit", "-qm", "init")
+	gitRun(t, repo, "branch", "merged")
+	gitRun(t, repo, "checkout", "-qb", "ahead")
+	gitRun(t, repo, "commit", "-q", "--allow-empty", "-m", "ahead")
+	gitRun(t, repo, "checkout", "-q", "main")
+	// An object nothing refers to
+	if err := os.WriteFile(filepath.Join(repo, "orphan"), []byte("unreferenced"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "hash-object", "-w", "orphan")
+	if err := os.MkdirAll(filepath.Join(repo, ".git", "rr-cache", "abc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ".git", "rr-cache", "abc", "preimage"), []byte("conflict"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	findings := scanDirectory(repo, nil, nil)
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 repository, got %d", len(findings))
+	}
+	breakDown(findings)
+	f := findings[0]
+	b := f.Breakdown
+	if b == nil || len(b.Errors) != 0 {
+		t.Fatalf("unexpected breakdown: %+v", b)
+	}
+	if b.UnreachableObjects != 1 || b.UnreachableBytes == 0 {
+		t.Fatalf("expected 1 unreachable object, got %d (%d bytes)", b.UnreachableObjects, b.UnreachableBytes)
+	}
+	if len(b.MergedBranches) != 1 || b.MergedBranches[0] != "merged" {
+		t.Fatalf("expected only the merged branch, got %v", b.MergedBranches)
+	}
+	if b.LooseBytes == 0 || b.RerereBytes != 8 || b.PackBytes != 0 {
+		t.Fatalf("unexpected sizes: %+v", b)
+	}
+	parts := b.PackBytes + b.LooseBytes + b.LFSBytes + b.RerereBytes + b.LogsBytes + b.HooksBytes + b.OtherBytes
+	if parts != f.SizeBytes || b.OtherBytes < 0 {
+		t.Fatalf("parts add up to %d, want %d: %+v", parts, f.SizeBytes, b)
+	}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	displayBreakdown(findings)
+	_ = w.Close()
+	os.Stdout = old
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+	if !strings.Contains(buf.String(), "merged") || !strings.Contains(buf.String(), "1 (") {
+		t.Fatalf("unexpected breakdown table:\n%s", buf.String())
+	}
+}
+
+func TestSlimShallow(t *testing.T) {
+	root := t.TempDir()
+	origin := filepath.Join(root, "origin")
+	gitRun(t, root, "init", "-q", "-b", "main", origin)
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(filepath.Join(origin, "README"), []byte(strings.Repeat("x", i+1)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		gitRun(t, origin, "add", "README")
+		gitRun(t, origin, "commit", "-qm", "commit")
+	}
+	gitRun(t, origin, "tag", "v1", "HEAD~2")
+	gitRun(t, origin, "branch", "old", "HEAD~1")
+	clone := filepath.Join(root, "clone")
+	gitRun(t, root, "clone", "-q", "file://"+origin, clone)
+	// old is now a stale remote-tracking branch; both it and v1 hold on to older history
+	gitRun(t, origin, "branch", "-D", "old")
+
+	p := gcPolicy{strategy: StrategyAuto, slim: SlimShallow, slimDepth: 1, inactiveFor: 24 * time.Hour}
+	findings := scanDirectory(clone, nil, nil)
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 repository, got %d", len(findings))
+	}
+	planActions(findings, nil, p)
+	if f := findings[0]; f.Action != StrategySlim || !strings.HasPrefix(f.Skip, "active: last commit") {
+		t.Fatalf("expected a recently active repository to be skipped, got %q %q", f.Action, f.Skip)
+	}
+
+	p.inactiveFor = 0
+	unsafe := []struct {
+		name string
+		make func()
+		undo func()
+		want string
+	}{
+		{"untracked", func() { _ = os.WriteFile(filepath.Join(clone, "notes"), []byte("x"), 0o644) }, func() { _ = os.Remove(filepath.Join(clone, "notes")) }, "1 untracked files"},
+		{"changed", func() { _ = os.WriteFile(filepath.Join(clone, "README"), []byte("edit"), 0o644) }, func() { gitRun(t, clone, "checkout", "README") }, "1 changed files"},
+		{"stash", func() {
+			_ = os.WriteFile(filepath.Join(clone, "README"), []byte("stash"), 0o644)
+			gitRun(t, clone, "stash", "-q")
+		}, func() { gitRun(t, clone, "stash", "drop", "-q") }, "1 stashes"},
+		{"unpushed", func() { gitRun(t, clone, "commit", "-q", "--allow-empty", "-m", "local") }, func() { gitRun(t, clone, "reset", "-q", "--hard", "origin/main") }, "1 unpushed commits"},
+	}
+	for _, tc := range unsafe {
+		tc.make()
+		planActions(findings, nil, p)
+		if got := findings[0].Skip; got != "local work: "+tc.want {
+			t.Errorf("%s: expected skip %q, got %q", tc.name, "local work: "+tc.want, got)
+		}
+		tc.undo()
+	}
+
+	planActions(findings, nil, p)
+	f := &findings[0]
+	if f.Skip != "" || f.Audit == nil || !f.Audit.HasOrigin || strings.Join(f.Audit.OriginTags, ",") != "v1" {
+		t.Fatalf("expected a clean clone to be slimmed, got skip %q audit %+v", f.Skip, f.Audit)
+	}
+	cleanRepo(f, p)
+	if !f.GC.OK {
+		t.Fatalf("slim failed: %s\n%s", f.GC.Error, f.GC.Output)
+	}
+	out, err := exec.Command("git", "-C", clone, "rev-list", "--count", "--all").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "1" {
+		t.Fatalf("expected 1 commit of history after slimming, got %q (%v)", out, err)
+	}
+	if out, _ := exec.Command("git", "-C", clone, "for-each-ref", "refs/tags/", "refs/remotes/origin/old").Output(); len(out) != 0 {
+		t.Fatalf("expected the tag and the stale branch to be dropped, got %q", out)
+	}
+	if out, _ := exec.Command("git", "-C", clone, "status", "--porcelain").Output(); len(out) != 0 {
+		t.Fatalf("expected the worktree to be untouched, got %q", out)
+	}
+
+	planActions(findings, nil, p)
+	if findings[0].Skip != "already shallow" {
+		t.Fatalf("expected a shallow repository to be skipped, got %q", findings[0].Skip)
+	}
+	planActions(findings, []RepoOverride{{Path: clone, Strategy: StrategyNever}}, p)
+	if findings[0].Action != StrategyNever || findings[0].Audit != nil {
+		t.Fatalf("expected the never override to win over --slim, got %+v", findings[0])
+	}
+}
+
+func TestSlimShallowMirror(t *testing.T) {
+	root := t.TempDir()
+	origin := filepath.Join(root, "origin")
+	gitRun(t, root, "init", "-q", "-b", "main", origin)
+	for i := 0; i < 3; i++ {
+		gitRun(t, origin, "commit", "-q", "--allow-empty", "-m", "commit")
+	}
+	mirror := filepath.Join(root, "mirror.git")
+	gitRun(t, root, "clone", "-q", "--mirror", "file://"+origin, mirror)
+
+	p := gcPolicy{strategy: StrategyAuto, slim: SlimShallow, slimDepth: 1}
+	findings := scanDirectory(mirror, nil, nil)
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 repository, got %d", len(findings))
+	}
+	gitRun(t, mirror, "update-ref", "refs/heads/local", strings.TrimSpace(gitOutput(t, mirror, "commit-tree", "-p", "main", "-m", "local", "main^{tree}")))
+	planActions(findings, nil, p)
+	if got := findings[0].Skip; got != "local work: 1 unpushed commits" {
+		t.Fatalf("expected a mirror with a local commit to be skipped, got %q", got)
+	}
+
+	gitRun(t, mirror, "branch", "-D", "local")
+	planActions(findings, nil, p)
+	f := &findings[0]
+	if f.Skip != "" || !f.Audit.Mirror || len(f.Audit.OriginTags) != 0 {
+		t.Fatalf("expected an unchanged mirror to be slimmed, got skip %q audit %+v", f.Skip, f.Audit)
+	}
+	cleanRepo(f, p)
+	if !f.GC.OK {
+		t.Fatalf("slim failed: %s\n%s", f.GC.Error, f.GC.Output)
+	}
+	if out := gitOutput(t, mirror, "rev-list", "--count", "--all"); strings.TrimSpace(out) != "1" {
+		t.Fatalf("expected 1 commit of history after slimming, got %q", out)
+	}
+}
+
+func TestSlimSkipWithoutOrigin(t *testing.T) {
+	root := t.TempDir()
+	gitRun(t, root, "init", "-q", "src")
+	gitRun(t, filepath.Join(root, "src"), "commit", "-q", "--allow-empty", "-m", "init")
+	clone := filepath.Join(root, "clone")
+	gitRun(t, root, "clone", "-q", "-o", "upstream", "src", clone)
+
+	findings := scanDirectory(clone, nil, nil)
+	planActions(findings, nil, gcPolicy{strategy: StrategyAuto, slim: SlimShallow, slimDepth: 1})
+	if got := findings[0].Skip; got != "no origin remote to refetch from" {
+		t.Fatalf("unexpected skip %q", got)
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		out          string
+		major, minor int
+		want         bool
+	}{
+		{"git version 2.39.5\n", 2, 43, false},
+		{"git version 2.43.0\n", 2, 43, true},
+		{"git version 2.45.1.windows.1\n", 2, 43, true},
+		{"git version 3.0.0\n", 2, 43, true},
+		{"", 2, 43, false},
+	}
+	for _, tt := range tests {
+		if got := versionAtLeast(tt.out, tt.major, tt.minor); got != tt.want {
+			t.Errorf("versionAtLeast(%q, %d, %d) = %v, want %v", tt.out, tt.major, tt.minor, got, tt.want)
+		}
+	}
+}
+
+func TestParseBranches(t *testing.T) {
+	out := "main\torigin/main\tahead 2, behind 1\nfeature\t\t\nold\torigin/old\tgone\nsynced\torigin/synced\t\nbehind\torigin/behind\tbehind 3\nlast"
+	ahead, noUpstream := parseBranches(out)
+	if len(ahead) != 1 || ahead[0] != (BranchAhead{Branch: "main", Commits: 2}) {
+		t.Fatalf("unexpected ahead branches: %+v", ahead)
+	}
+	if strings.Join(noUpstream, ",") != "feature,old,last" {
+		t.Fatalf("unexpected branches without upstream: %v", noUpstream)
+	}
+}
+
+func TestReadAudit(t *testing.T) {
+	root := t.TempDir()
+	origin := filepath.Join(root, "origin")
+	gitRun(t, root, "init", "-q", "-b", "main", origin)
+	gitRun(t, origin, "commit", "-q", "--allow-empty", "-m", "init")
+	clone := filepath.Join(root, "clone")
+	gitRun(t, root, "clone", "-q", "file://"+origin, clone)
+	synced := filepath.Join(root, "synced")
+	gitRun(t, root, "clone", "-q", "file://"+origin, synced)
+
+	gitRun(t, clone, "commit", "-q", "--allow-empty", "-m", "ahead")
+	gitRun(t, clone, "branch", "topic")
+	if err := os.WriteFile(filepath.Join(clone, "notes"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	findings := scanDirectory(root, nil, nil)
+	audit(findings)
+	byPath := map[string]*Audit{}
+	for _, f := range findings {
+		byPath[f.RepoPath] = f.Audit
+	}
+
+	a := byPath[clone]
+	if a == nil || len(a.Errors) != 0 {
+		t.Fatalf("unexpected audit: %+v", a)
+	}
+	if a.Untracked != 1 || a.Unpushed != 1 || len(a.Ahead) != 1 || a.Ahead[0].Branch != "main" || strings.Join(a.NoUpstream, ",") != "topic" {
+		t.Fatalf("unexpected local work: %+v", a)
+	}
+	if a.SafeToSlim || a.SafeToArchive || a.SafeToDelete {
+		t.Fatalf("expected nothing to be safe: %+v", a)
+	}
+	if s := byPath[synced]; s == nil || !s.SafeToSlim || !s.SafeToArchive || !s.SafeToDelete {
+		t.Fatalf("expected a synced clone to be safe for everything: %+v", s)
+	}
+	// The origin has no remote, so its commits are unpushed
+	if o := byPath[origin]; o == nil || o.Unpushed != 1 || o.HasOrigin || o.SafeToSlim || !o.SafeToArchive {
+		t.Fatalf("unexpected origin audit: %+v", o)
+	}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	displayAudit(findings)
+	_ = w.Close()
+	os.Stdout = old
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+	if out := buf.String(); !strings.Contains(out, "main +1") || !strings.Contains(out, "slim, archive, delete") || !strings.Contains(out, "1 slim, 2 archive, 1 delete") {
+		t.Fatalf("unexpected audit table:\n%s", out)
+	}
+}
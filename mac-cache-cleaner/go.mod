@@ -3,6 +3,7 @@ module mac-cache-cleaner
 go 1.21
 
 require (
+	cachecore v0.0.0
 	github.com/olekukonko/tablewriter v1.1.3
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -20,3 +21,5 @@ require (
 	github.com/olekukonko/ll v0.1.4-0.20260115111900-9e59c2286df0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 )
+
+replace cachecore => ../cachecore
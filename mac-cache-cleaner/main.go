@@ -1,21 +1,28 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
 	yaml "gopkg.in/yaml.v3"
+
+	"cachecore"
 )
 
 // ----- Version info -----
@@ -53,7 +60,7 @@ var allowedCommandSubstitutions = map[string]func([]string) (string, error){
 
 // ----- CLI flags -----
 var (
-	flagClean       = flag.Bool("clean", false, "Run safe CLI clean commands (default: dry-run)")
+	flagClean       = flag.Bool("clean", false, "Run safe CLI clean commands and enabled delete policies (default: dry-run)")
 	flagTargets     = flag.String("targets", "all", "Comma-separated targets to scan/clean (or 'all')")
 	flagJSON        = flag.Bool("json", false, "Output results as JSON")
 	flagConfig      = flag.String("config", defaultConfigPath(), "Path to YAML config")
@@ -63,6 +70,12 @@ var (
 	flagListTargets = flag.Bool("list-targets", false, "List all available targets and exit")
 	flagCheckTools  = flag.Bool("check-tools", false, "Check if required tools are installed and exit")
 	flagDetails     = flag.Bool("details", false, "Show detailed per-directory information")
+	flagJobs        = flag.Int("jobs", cachecore.DefaultJobs(), "Max target paths sized concurrently")
+	flagOnDisk      = flag.Bool("on-disk", false, "Report allocated on-disk usage (like du) instead of apparent size")
+	flagNoIndex     = flag.Bool("no-index", false, "Walk every target path instead of reusing unchanged directories from the size index")
+	flagExclude     = cachecore.PatternsFlag("exclude", "Skip target paths and directories under them matching this pattern, e.g. com.apple.* (repeatable)")
+	flagNoHistory   = flag.Bool("no-history", false, "Do not append this run to the scan history (see history)")
+	flagSourceRoot  = cachecore.PatternsFlag("source-root", "Directory searched for files that pin tool versions, e.g. ~/src (repeatable; default: sourceRoots from the config)")
 )
 
 // ----- Config types -----
@@ -74,7 +87,10 @@ type Config struct {
 }
 
 type Options struct {
-	DockerPruneByDefault bool `yaml:"dockerPruneByDefault"`
+	DockerPruneByDefault bool     `yaml:"dockerPruneByDefault"`
+	Exclude              []string `yaml:"exclude,omitempty"`        // Path globs left out of the size report, like --exclude
+	SourceRoots          []string `yaml:"sourceRoots,omitempty"`    // Directories searched for files that pin tool versions, like --source-root
+	CommandTimeout       string   `yaml:"commandTimeout,omitempty"` // Default time a clean command may run before it is killed, e.g. 30m
 }
 
 type Tool struct {
@@ -86,80 +102,128 @@ type Tool struct {
 }
 
 type Target struct {
-	Name    string     `yaml:"name"`
-	Enabled bool       `yaml:"enabled"`
-	Notes   string     `yaml:"notes"`
-	Paths   []string   `yaml:"paths"` // measured for size only
-	Cmds    [][]string `yaml:"cmds"`  // commands to run when --clean is set
-	Tools   []Tool     `yaml:"tools"` // required tools for this target
+	Name     string                   `yaml:"name"`
+	Enabled  bool                     `yaml:"enabled"`
+	Notes    string                   `yaml:"notes"`
+	Paths    []string                 `yaml:"paths"`              // measured for size only
+	Cmds     []Command                `yaml:"cmds"`               // commands to run when --clean is set
+	Tools    []Tool                   `yaml:"tools"`              // required tools for this target
+	Delete   *DeletePolicy            `yaml:"delete,omitempty"`   // direct deletion under paths when --clean is set (opt-in)
+	Versions *VersionPolicy           `yaml:"versions,omitempty"` // removal of unpinned installed versions when --clean is set (opt-in)
+	OS       map[string]TargetVariant `yaml:"os,omitempty"`       // per-OS overrides, keyed by GOOS (darwin, linux, ...)
+}
+
+// DeletePolicy lets --clean remove the entries (direct children) of a target's
+// paths itself, for caches no tool can clean. Nothing is deleted unless it is
+// enabled, and then only entries that pass every filter.
+type DeletePolicy struct {
+	Enabled       bool     `yaml:"enabled"`
+	OlderThan     string   `yaml:"olderThan,omitempty"`     // only entries with nothing modified for this long, e.g. 30d
+	Include       []string `yaml:"include,omitempty"`       // entry name globs to delete (default: all)
+	Exclude       []string `yaml:"exclude,omitempty"`       // entry name globs to keep
+	UnlessRunning []string `yaml:"unlessRunning,omitempty"` // process names; nothing is deleted while one is running
+}
+
+// TargetVariant replaces parts of a Target on one OS. Fields left out keep the
+// target's own values; an empty list (e.g. cmds: []) clears them.
+type TargetVariant struct {
+	Enabled *bool         `yaml:"enabled,omitempty"`
+	Notes   string        `yaml:"notes,omitempty"`
+	Paths   []string      `yaml:"paths,omitempty"`
+	Cmds    []Command     `yaml:"cmds,omitempty"`
+	Tools   []Tool        `yaml:"tools,omitempty"`
+	Delete  *DeletePolicy `yaml:"delete,omitempty"`
+}
+
+// forOS returns the target with the variant for goos applied.
+func (t Target) forOS(goos string) Target {
+	v, ok := t.OS[goos]
+	if !ok {
+		return t
+	}
+	if v.Enabled != nil {
+		t.Enabled = *v.Enabled
+	}
+	if v.Notes != "" {
+		t.Notes = v.Notes
+	}
+	if v.Paths != nil {
+		t.Paths = v.Paths
+	}
+	if v.Cmds != nil {
+		t.Cmds = v.Cmds
+	}
+	if v.Tools != nil {
+		t.Tools = v.Tools
+	}
+	if v.Delete != nil {
+		t.Delete = v.Delete
+	}
+	return t
 }
 
 // ----- Report types -----
 
+// CmdResult is a clean command: before --clean whether it can run, and after
+// it what it did.
 type CmdResult struct {
-	Cmd   []string `json:"cmd"`
-	Found bool     `json:"found"`
-	Error string   `json:"error,omitempty"`
+	Cmd            []string   `json:"cmd"`
+	Found          bool       `json:"found"`
+	Error          string     `json:"error,omitempty"`
+	ExitCode       *int       `json:"exit_code,omitempty"` // Unset if the command did not run or was killed
+	Start          *time.Time `json:"start,omitempty"`
+	End            *time.Time `json:"end,omitempty"`
+	DurationMS     int64      `json:"duration_ms,omitempty"`
+	Stdout         string     `json:"stdout_tail,omitempty"`     // Last cmdTailBytes of output; not captured for tty commands
+	Stderr         string     `json:"stderr_tail,omitempty"`     // Last cmdTailBytes of error output; not captured for tty commands
+	ReclaimedBytes int64      `json:"reclaimed_bytes,omitempty"` // Space the command says it freed (docker, podman, brew)
 }
 
-type Finding struct {
+// DeleteResult is an entry a delete policy removes, or would remove in a dry run.
+type DeleteResult struct {
 	Path      string    `json:"path"`
 	SizeBytes int64     `json:"size_bytes"`
-	Items     int       `json:"items"`
-	Err       string    `json:"error,omitempty"`
 	ModMax    time.Time `json:"latest_mtime"`
+	Deleted   bool      `json:"deleted"`
+	Error     string    `json:"error,omitempty"`
 }
 
+type Finding = cachecore.Finding
+
 type Report struct {
-	Hostname string                 `json:"hostname"`
-	OS       string                 `json:"os"`
-	Arch     string                 `json:"arch"`
-	DryRun   bool                   `json:"dry_run"`
-	When     time.Time              `json:"when"`
-	Totals   map[string]uint64      `json:"totals_by_target_bytes"`
-	Findings map[string][]Finding   `json:"findings"`
-	Commands map[string][]CmdResult `json:"commands"`
-	Warnings []string               `json:"warnings"`
+	cachecore.Envelope
+	Totals   map[string]uint64          `json:"totals_by_target_bytes"`
+	Findings map[string][]Finding       `json:"findings"`
+	Commands map[string][]CmdResult     `json:"commands"`
+	Deletes  map[string][]DeleteResult  `json:"deletes,omitempty"`               // Entries of targets with an enabled delete policy
+	Versions map[string][]VersionResult `json:"versions,omitempty"`              // Installed versions of targets with an enabled version policy
+	Freed    map[string]int64           `json:"freed_by_target_bytes,omitempty"` // Set by --json --clean from the re-scan
+	Excluded []cachecore.ExcludedPath   `json:"excluded"`                        // Paths left out of the first scan
+	Warnings []string                   `json:"warnings"`
 }
 
 // ----- Utilities -----
 
-func defaultConfigPath() string {
-	h, _ := os.UserHomeDir()
-	if h == "" {
-		return "./config.yaml"
-	}
-	return filepath.Join(h, ".config", "mac-cache-cleaner", "config.yaml")
-}
+func defaultConfigPath() string { return cachecore.DefaultConfigPath("mac-cache-cleaner") }
 
-func ensureDir(p string) error { return os.MkdirAll(filepath.Dir(p), 0o755) }
-
-func home() string           { h, _ := os.UserHomeDir(); return h }
-func expand(p string) string { return os.ExpandEnv(strings.ReplaceAll(p, "~", home())) }
+var (
+	expand           = cachecore.Expand
+	human            = cachecore.Human
+	checkVersionFlag = cachecore.CheckVersionFlag
+	inspectPath      = cachecore.Inspect
+)
 
-func checkVersionFlag() bool {
-	for _, arg := range os.Args[1:] {
-		if arg == "-version" || arg == "--version" {
-			return true
-		}
-	}
-	return false
+// newSizer returns a directory sizer configured from --jobs, --on-disk, --no-index and the exclusions.
+func newSizer() cachecore.Sizer {
+	return cachecore.Sizer{Jobs: *flagJobs, OnDisk: *flagOnDisk, Index: sizeIndex, Exclude: excluder}
 }
 
-func human(n int64) string {
-	if n < 1024 {
-		return fmt.Sprintf("%d B", n)
-	}
-	units := []string{"KB", "MB", "GB", "TB"}
-	v := float64(n)
-	for i, u := range units {
-		v /= 1024
-		if v < 1024 || i == len(units)-1 {
-			return fmt.Sprintf("%.2f %s", v, u)
-		}
-	}
-	return fmt.Sprintf("%.2f TB", v/1024)
-}
+// sizeIndex caches directory totals between runs; nil with --no-index.
+var sizeIndex *cachecore.Index
+
+// excluder leaves the paths ruled out by --exclude, the config and ignore files
+// out of the size report; nil excludes nothing. Clean commands are unaffected.
+var excluder *cachecore.Excluder
 
 // wrapText wraps text at the specified width, breaking at word boundaries when possible
 func wrapText(text string, width int) string {
@@ -255,6 +319,12 @@ func parseHumanSize(s string) (int64, bool) {
 	}
 }
 
+// dockerFinding builds a finding for one row of docker system df. Docker only reports a
+// single size, so it is used as both the apparent and the on-disk figure.
+func dockerFinding(typ string, sizeBytes int64, items int) Finding {
+	return Finding{Path: "docker:" + strings.ToLower(typ), SizeBytes: sizeBytes, ApparentBytes: sizeBytes, DiskBytes: sizeBytes, Items: items}
+}
+
 // dockerSystemDF gathers Docker disk usage via `docker system df` and returns findings and total bytes
 func dockerSystemDF() ([]Finding, int64, error) {
 	// Prefer JSON output; fallback to line-delimited json template if necessary
@@ -300,7 +370,7 @@ func dockerSystemDF() ([]Finding, int64, error) {
 					}
 				}
 			}
-			f := Finding{Path: "docker:" + strings.ToLower(typ), SizeBytes: sizeBytes, Items: items}
+			f := dockerFinding(typ, sizeBytes, items)
 			findings = append(findings, f)
 			total += sizeBytes
 		}
@@ -343,7 +413,7 @@ func dockerSystemDF() ([]Finding, int64, error) {
 							}
 						}
 					}
-					f := Finding{Path: "docker:" + strings.ToLower(typ), SizeBytes: sizeBytes, Items: items}
+					f := dockerFinding(typ, sizeBytes, items)
 					findings = append(findings, f)
 					total += sizeBytes
 				}
@@ -356,42 +426,6 @@ func dockerSystemDF() ([]Finding, int64, error) {
 	return tryTemplate()
 }
 
-func inspectPath(root string) (Finding, error) {
-	f := Finding{Path: root}
-	fi, err := os.Stat(root)
-	if err != nil {
-		return f, err
-	}
-	if !fi.IsDir() {
-		f.Items = 1
-		f.SizeBytes = fi.Size()
-		f.ModMax = fi.ModTime()
-		return f, nil
-	}
-	errWalk := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			if f.Err == "" {
-				f.Err = err.Error()
-			}
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		info, e := d.Info()
-		if e != nil {
-			return nil
-		}
-		f.Items++
-		f.SizeBytes += info.Size()
-		if info.ModTime().After(f.ModMax) {
-			f.ModMax = info.ModTime()
-		}
-		return nil
-	})
-	return f, errWalk
-}
-
 func expandGlobs(pattern string) ([]string, error) {
 	pattern = expand(pattern)
 
@@ -454,84 +488,136 @@ func expandGlobs(pattern string) ([]string, error) {
 
 // ----- Config IO -----
 
+// writeStarterConfig writes the starter catalogue for the host OS to path.
 func writeStarterConfig(path string, force bool) error {
-	// Check if file exists
-	if _, err := os.Stat(path); err == nil {
-		if !force {
-			return fmt.Errorf("config file already exists at %s. Use --force to overwrite", path)
-		}
-		// Backup existing file with timestamp
-		backupPath := fmt.Sprintf("%s.%s", path, time.Now().Format("20060102-150405"))
-		if err := os.Rename(path, backupPath); err != nil {
-			return fmt.Errorf("failed to backup existing config: %w", err)
-		}
-		fmt.Printf("Existing config backed up to: %s\n", backupPath)
-	}
-
-	starter := Config{
-		Version: 1,
-		Options: Options{DockerPruneByDefault: false},
-		Targets: []Target{
-			{Name: "docker", Enabled: true, Notes: "Docker caches and images (safe CLI prune only)", Paths: []string{"~/Library/Caches/docker", "~/Library/Caches/buildx", "~/Library/Containers/com.docker.docker/Data/vms/0/data/Docker.raw"}, Cmds: [][]string{{"docker", "builder", "prune", "-af"}, {"docker", "system", "prune", "-af", "--volumes"}}, Tools: []Tool{{Name: "docker", InstallCmd: "brew install --cask docker"}}},
-			{Name: "brew", Enabled: true, Notes: "Homebrew cleanup (removes old packages and caches)", Paths: []string{"~/Library/Caches/Homebrew", "$(brew --cache)"}, Cmds: [][]string{{"brew", "cleanup", "-s"}, {"brew", "autoremove"}}, Tools: []Tool{{Name: "brew", InstallCmd: "/bin/bash -c \"$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)\""}}},
-			{Name: "npm", Enabled: true, Notes: "npm cache", Paths: []string{"~/.npm"}, Cmds: [][]string{{"npm", "cache", "clean", "--force"}}, Tools: []Tool{{Name: "npm", InstallCmd: "brew install node"}}},
-			{Name: "yarn", Enabled: true, Notes: "Global Yarn cache", Paths: []string{"~/Library/Caches/Yarn", "~/.yarn/cache"}, Cmds: [][]string{{"yarn", "cache", "clean"}}, Tools: []Tool{{Name: "yarn", InstallCmd: "brew install yarn"}}},
-			{Name: "pnpm", Enabled: true, Notes: "pnpm store and cache", Paths: []string{"~/.pnpm-store", "~/Library/Caches/pnpm"}, Cmds: [][]string{{"pnpm", "store", "prune"}}, Tools: []Tool{{Name: "pnpm", InstallCmd: "brew install pnpm"}}},
-			{Name: "node-versions", Enabled: true, Notes: "Node version manager (nvm)", Paths: []string{"~/.nvm/.cache"}, Cmds: [][]string{{"nvm", "cache", "clear"}}, Tools: []Tool{{Name: "nvm", InstallCmd: "curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/v0.40.3/install.sh | bash", InstallNotes: "After installation, restart your terminal or run: source ~/.bashrc or source ~/.zshrc", CheckPath: "~/.nvm/nvm.sh"}}},
-			{Name: "expo", Enabled: true, Notes: "Expo and React Native caches", Paths: []string{"~/.expo", "~/.cache/expo"}, Cmds: [][]string{{"expo", "start", "-c"}}, Tools: []Tool{{Name: "expo", InstallCmd: "npm install -g expo-cli"}}},
-			{Name: "go", Enabled: true, Notes: "Go build & module caches", Paths: []string{"~/Library/Caches/go-build", "$GOMODCACHE/cache", "$GOPATH/pkg/mod/cache"}, Cmds: [][]string{{"go", "clean", "-cache", "-testcache", "-modcache"}}, Tools: []Tool{{Name: "go", InstallCmd: "brew install go"}}},
-			{Name: "rust", Enabled: true, Notes: "Rust registry and build caches (requires cargo-cache: cargo install cargo-cache)", Paths: []string{"~/.cargo/registry", "~/.cargo/git"}, Cmds: [][]string{{"cargo", "cache", "-a"}}, Tools: []Tool{{Name: "cargo", InstallCmd: "curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh"}, {Name: "cargo-cache", InstallCmd: "cargo install cargo-cache", InstallNotes: "Install this after cargo is installed"}}},
-			{Name: "python", Enabled: true, Notes: "pip and pipenv caches", Paths: []string{"~/.cache/pip", "~/Library/Caches/pip", "~/.local/share/virtualenvs"}, Cmds: [][]string{{"pip", "cache", "purge"}}, Tools: []Tool{{Name: "pip", InstallCmd: "brew install python", InstallNotes: "pip is included with Python installation"}}},
-			{Name: "python-poetry", Enabled: true, Notes: "Poetry package manager cache", Paths: []string{"~/Library/Caches/pypoetry"}, Cmds: [][]string{{"poetry", "cache", "clear", "--all", "pypi"}}, Tools: []Tool{{Name: "poetry", InstallCmd: "brew install poetry"}}},
-			{Name: "python-uv", Enabled: true, Notes: "uv Python package installer cache", Paths: []string{"~/.cache/uv"}, Cmds: [][]string{{"uv", "cache", "clean"}}, Tools: []Tool{{Name: "uv", InstallCmd: "curl -LsSf https://astral.sh/uv/install.sh | sh", InstallNotes: "uv is a fast Python package installer"}}},
-			{Name: "conda", Enabled: true, Notes: "Conda package and cache cleanup", Paths: []string{"~/.conda/pkgs", "~/.conda/envs"}, Cmds: [][]string{{"conda", "clean", "-a", "-y"}}, Tools: []Tool{{Name: "conda", InstallCmd: "brew install miniconda"}}},
-			{Name: "maven", Enabled: true, Notes: "Maven local repo purge (safe via plugin)", Paths: []string{"~/.m2/repository"}, Cmds: [][]string{{"mvn", "-q", "dependency:purge-local-repository", "-DreResolve=false"}}, Tools: []Tool{{Name: "mvn", InstallCmd: "brew install maven"}}},
-			{Name: "gradle", Enabled: true, Notes: "Gradle build caches and wrappers", Paths: []string{"~/.gradle/caches", "~/.gradle/wrapper/dists"}, Cmds: [][]string{}, Tools: []Tool{{Name: "gradle", InstallCmd: "brew install gradle"}}},
-			{Name: "xcode", Enabled: true, Notes: "Xcode build artifacts and caches", Paths: []string{"~/Library/Developer/Xcode/DerivedData", "~/Library/Developer/Xcode/Archives", "~/Library/Developer/Xcode/ModuleCache.noindex"}, Cmds: [][]string{{"xcrun", "simctl", "delete", "unavailable"}}},
-			{Name: "ruby", Enabled: true, Notes: "Ruby and Bundler caches", Paths: []string{"~/.gem/cache", "~/.bundle/cache"}, Cmds: [][]string{{"gem", "cleanup"}, {"bundle", "clean", "--force"}}, Tools: []Tool{{Name: "gem", InstallCmd: "brew install ruby", InstallNotes: "gem is included with Ruby installation"}, {Name: "bundle", InstallCmd: "gem install bundler"}}},
-			{Name: "php", Enabled: true, Notes: "Composer PHP cache", Paths: []string{"~/.composer/cache"}, Cmds: [][]string{{"composer", "clear-cache"}}, Tools: []Tool{{Name: "composer", InstallCmd: "brew install composer"}}},
-			{Name: "dotnet", Enabled: true, Notes: ".NET SDK and NuGet caches", Paths: []string{"~/.nuget/packages", "~/.dotnet/tools"}, Cmds: [][]string{{"dotnet", "nuget", "locals", "all", "--clear"}}, Tools: []Tool{{Name: "dotnet", InstallCmd: "brew install --cask dotnet"}}},
-			{Name: "vscode", Enabled: true, Notes: "VS Code caches and logs", Paths: []string{"~/Library/Application Support/Code/Cache", "~/Library/Application Support/Code/CachedData", "~/Library/Application Support/Code/GPUCache", "~/Library/Application Support/Code/logs"}, Cmds: [][]string{}},
-			{Name: "jetbrains", Enabled: true, Notes: "JetBrains IDE caches (IntelliJ, PyCharm, WebStorm, etc.)", Paths: []string{"~/Library/Caches/JetBrains", "~/Library/Logs/JetBrains", "~/Library/Application Support/JetBrains/*/system/caches"}, Cmds: [][]string{}},
-			{Name: "build-tools", Enabled: true, Notes: "Compiler and build caches (ccache, bazel, Xcode)", Paths: []string{"~/.ccache", "~/.bazel-cache", "~/.cache/bazel"}, Cmds: [][]string{{"ccache", "-C"}}, Tools: []Tool{{Name: "ccache", InstallCmd: "brew install ccache"}}},
-			{Name: "chrome", Enabled: true, Notes: "Chrome cache (informational only)", Paths: []string{"~/Library/Caches/Google/Chrome", "~/Library/Application Support/Google/Chrome/*/Cache"}, Cmds: [][]string{}},
-			{Name: "macos", Enabled: false, Notes: "macOS system caches (advanced users only)", Paths: []string{"~/Library/Caches", "~/Library/Containers/com.apple.QuickLook.thumbnailcache"}, Cmds: [][]string{{"qlmanage", "-r", "cache"}}},
-			{Name: "flutter", Enabled: true, Notes: "Flutter and Dart caches (pub, SDK, and analysis artifacts)", Paths: []string{"~/.pub-cache", "~/.dartServer", "~/Library/Developer/flutter", "~/Library/Caches/flutter"}, Cmds: [][]string{{"flutter", "pub", "cache", "clean", "--force"}}, Tools: []Tool{{Name: "flutter", InstallCmd: "Install Flutter manually", InstallNotes: "For installation instructions, visit: https://docs.flutter.dev/install/manual"}}},
-			{Name: "android", Enabled: true, Notes: "Android SDK and emulator caches", Paths: []string{"~/.android/cache", "~/.android/avd", "~/Library/Android/sdk"}, Cmds: [][]string{{"sdkmanager", "--update"}}},
-			{Name: "android-studio", Enabled: true, Notes: "Android Studio IDE caches, logs, and indexes", Paths: []string{"~/Library/Caches/Google/AndroidStudio*", "~/Library/Logs/Google/AndroidStudio*", "~/Library/Application Support/Google/AndroidStudio*/system/caches", "~/Library/Application Support/Google/AndroidStudio*/system/index"}, Cmds: [][]string{}},
-			{Name: "terraform", Enabled: true, Notes: "Terraform plugin cache", Paths: []string{"~/.terraform.d/plugin-cache/"}, Cmds: [][]string{}, Tools: []Tool{{Name: "terraform", InstallCmd: "brew install terraform"}}},
-			{Name: "packer", Enabled: true, Notes: "Packer plugins directory", Paths: []string{"~/.packer.d/plugins"}, Cmds: [][]string{}, Tools: []Tool{{Name: "packer", InstallCmd: "brew install packer"}}},
-			{Name: "ollama", Enabled: true, Notes: "Ollama models and cache (uses official prune)", Paths: []string{"~/.ollama/models"}, Cmds: [][]string{{"ollama", "list"}}, Tools: []Tool{{Name: "ollama", InstallCmd: "brew install ollama"}}},
-			{Name: "home-cache", Enabled: true, Notes: "Top-level ~/.cache subdirectories (informational only)", Paths: []string{"~/.cache/*"}, Cmds: [][]string{}, Tools: []Tool{}},
-			{Name: "pyenv", Enabled: true, Notes: "Pyenv installed versions and downloads (informational)", Paths: []string{"~/.pyenv/versions", "~/.pyenv/cache", "~/.pyenv/plugins/python-build/share/python-build/cache"}, Cmds: [][]string{}, Tools: []Tool{{Name: "pyenv", InstallCmd: "brew install pyenv", InstallNotes: "Remove unused versions with: pyenv uninstall <version>"}}},
-			{Name: "rustup", Enabled: true, Notes: "Rustup toolchains and targets (informational)", Paths: []string{"~/.rustup/toolchains", "~/.rustup/tmp", "~/.rustup/downloads"}, Cmds: [][]string{}, Tools: []Tool{{Name: "rustup", InstallCmd: "curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh", InstallNotes: "List toolchains: rustup toolchain list; remove: rustup toolchain uninstall <name>"}}},
-			{Name: "vscode-extensions", Enabled: true, Notes: "VS Code extensions and data under ~/.vscode (informational)", Paths: []string{"~/.vscode"}, Cmds: [][]string{}, Tools: []Tool{}},
-			{Name: "rvm", Enabled: true, Notes: "RVM installed rubies and archives (informational)", Paths: []string{"~/.rvm/rubies", "~/.rvm/archives", "~/.rvm/src"}, Cmds: [][]string{{"rvm", "cleanup", "all"}}, Tools: []Tool{{Name: "rvm", InstallCmd: "curl -sSL https://get.rvm.io | bash", InstallNotes: "List rubies: rvm list; remove: rvm remove <ruby>"}}},
-			{Name: "dropbox", Enabled: true, Notes: "Dropbox metadata and state (informational only; no safe CLI clean)", Paths: []string{"~/.dropbox"}, Cmds: [][]string{}, Tools: []Tool{}},
-			{Name: "cursor", Enabled: true, Notes: "Cursor editor state and cache (informational)", Paths: []string{"~/.cursor"}, Cmds: [][]string{}, Tools: []Tool{}},
-			{Name: "puppeteer", Enabled: true, Notes: "Puppeteer cache", Paths: []string{"~/.cache/puppeteer"}, Cmds: [][]string{{"sh", "-c", "npm list -g puppeteer >/dev/null 2>&1 || npm install -g puppeteer; NODE_PATH=$(npm root -g) node -e \"const puppeteer = require('puppeteer'); puppeteer.default.trimCache().then(() => process.exit(0)).catch((e) => {console.error(e); process.exit(1);})\""}}, Tools: []Tool{{Name: "node", InstallCmd: "brew install node"}}},
-		},
+	return cachecore.WriteConfig(path, force, starterConfig(runtime.GOOS))
+}
+
+// starterConfig returns the starter config for goos: XDG locations on Linux
+// and ~/Library locations everywhere else.
+func starterConfig(goos string) Config {
+	targets := macTargets()
+	if goos == "linux" {
+		targets = linuxTargets()
 	}
-	if err := ensureDir(path); err != nil {
-		return err
+	return Config{Version: 1, Options: Options{DockerPruneByDefault: false, SourceRoots: []string{"~/src"}}, Targets: targets}
+}
+
+// macTargets is the macOS starter catalogue.
+func macTargets() []Target {
+	return []Target{
+		{Name: "docker", Enabled: true, Notes: "Docker caches and images (safe CLI prune only)", Paths: []string{"~/Library/Caches/docker", "~/Library/Caches/buildx", "~/Library/Containers/com.docker.docker/Data/vms/0/data/Docker.raw"}, Cmds: []Command{{Args: []string{"docker", "builder", "prune", "-af"}}, {Args: []string{"docker", "system", "prune", "-af", "--volumes"}}}, Tools: []Tool{{Name: "docker", InstallCmd: "brew install --cask docker"}}},
+		{Name: "brew", Enabled: true, Notes: "Homebrew cleanup (removes old packages and caches)", Paths: []string{"~/Library/Caches/Homebrew", "$(brew --cache)"}, Cmds: []Command{{Args: []string{"brew", "cleanup", "-s"}}, {Args: []string{"brew", "autoremove"}}}, Tools: []Tool{{Name: "brew", InstallCmd: "/bin/bash -c \"$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)\""}}},
+		{Name: "npm", Enabled: true, Notes: "npm cache", Paths: []string{"~/.npm"}, Cmds: []Command{{Args: []string{"npm", "cache", "clean", "--force"}}}, Tools: []Tool{{Name: "npm", InstallCmd: "brew install node"}}},
+		{Name: "yarn", Enabled: true, Notes: "Global Yarn cache", Paths: []string{"~/Library/Caches/Yarn", "~/.yarn/cache"}, Cmds: []Command{{Args: []string{"yarn", "cache", "clean"}}}, Tools: []Tool{{Name: "yarn", InstallCmd: "brew install yarn"}}},
+		{Name: "pnpm", Enabled: true, Notes: "pnpm store and cache", Paths: []string{"~/.pnpm-store", "~/Library/Caches/pnpm"}, Cmds: []Command{{Args: []string{"pnpm", "store", "prune"}}}, Tools: []Tool{{Name: "pnpm", InstallCmd: "brew install pnpm"}}},
+		{Name: "node-versions", Enabled: true, Notes: "Node version manager (nvm)", Paths: []string{"~/.nvm/.cache", "~/.nvm/versions/node"}, Cmds: []Command{{Args: []string{"nvm", "cache", "clear"}}}, Tools: []Tool{{Name: "nvm", InstallCmd: "curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/v0.40.3/install.sh | bash", InstallNotes: "After installation, restart your terminal or run: source ~/.bashrc or source ~/.zshrc", CheckPath: "~/.nvm/nvm.sh"}}, Versions: &VersionPolicy{Manager: "nvm", Keep: 2}},
+		{Name: "expo", Enabled: true, Notes: "Expo and React Native caches", Paths: []string{"~/.expo", "~/.cache/expo"}, Cmds: []Command{{Args: []string{"expo", "start", "-c"}, Interaction: InteractionTTY}}, Tools: []Tool{{Name: "expo", InstallCmd: "npm install -g expo-cli"}}},
+		{Name: "go", Enabled: true, Notes: "Go build & module caches", Paths: []string{"~/Library/Caches/go-build", "$GOMODCACHE/cache", "$GOPATH/pkg/mod/cache"}, Cmds: []Command{{Args: []string{"go", "clean", "-cache", "-testcache", "-modcache"}}}, Tools: []Tool{{Name: "go", InstallCmd: "brew install go"}}},
+		{Name: "rust", Enabled: true, Notes: "Rust registry and build caches (requires cargo-cache: cargo install cargo-cache)", Paths: []string{"~/.cargo/registry", "~/.cargo/git"}, Cmds: []Command{{Args: []string{"cargo", "cache", "-a"}}}, Tools: []Tool{{Name: "cargo", InstallCmd: "curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh"}, {Name: "cargo-cache", InstallCmd: "cargo install cargo-cache", InstallNotes: "Install this after cargo is installed"}}},
+		{Name: "python", Enabled: true, Notes: "pip and pipenv caches", Paths: []string{"~/.cache/pip", "~/Library/Caches/pip", "~/.local/share/virtualenvs"}, Cmds: []Command{{Args: []string{"pip", "cache", "purge"}}}, Tools: []Tool{{Name: "pip", InstallCmd: "brew install python", InstallNotes: "pip is included with Python installation"}}},
+		{Name: "python-poetry", Enabled: true, Notes: "Poetry package manager cache", Paths: []string{"~/Library/Caches/pypoetry"}, Cmds: []Command{{Args: []string{"poetry", "cache", "clear", "--all", "pypi", "--no-interaction"}}}, Tools: []Tool{{Name: "poetry", InstallCmd: "brew install poetry"}}},
+		{Name: "python-uv", Enabled: true, Notes: "uv Python package installer cache", Paths: []string{"~/.cache/uv"}, Cmds: []Command{{Args: []string{"uv", "cache", "clean"}}}, Tools: []Tool{{Name: "uv", InstallCmd: "curl -LsSf https://astral.sh/uv/install.sh | sh", InstallNotes: "uv is a fast Python package installer"}}},
+		{Name: "conda", Enabled: true, Notes: "Conda package and cache cleanup", Paths: []string{"~/.conda/pkgs", "~/.conda/envs"}, Cmds: []Command{{Args: []string{"conda", "clean", "-a", "-y"}}}, Tools: []Tool{{Name: "conda", InstallCmd: "brew install miniconda"}}},
+		{Name: "maven", Enabled: true, Notes: "Maven local repo purge (safe via plugin)", Paths: []string{"~/.m2/repository"}, Cmds: []Command{{Args: []string{"mvn", "-q", "dependency:purge-local-repository", "-DreResolve=false"}}}, Tools: []Tool{{Name: "mvn", InstallCmd: "brew install maven"}}},
+		{Name: "gradle", Enabled: true, Notes: "Gradle build caches and wrappers", Paths: []string{"~/.gradle/caches", "~/.gradle/wrapper/dists"}, Cmds: []Command{}, Tools: []Tool{{Name: "gradle", InstallCmd: "brew install gradle"}}, Delete: &DeletePolicy{OlderThan: "30d"}, Versions: &VersionPolicy{Manager: "gradle", Keep: 2}},
+		{Name: "xcode", Enabled: true, Notes: "Xcode build artifacts and caches", Paths: []string{"~/Library/Developer/Xcode/DerivedData", "~/Library/Developer/Xcode/Archives", "~/Library/Developer/Xcode/ModuleCache.noindex"}, Cmds: []Command{{Args: []string{"xcrun", "simctl", "delete", "unavailable"}}}},
+		{Name: "ruby", Enabled: true, Notes: "Ruby and Bundler caches", Paths: []string{"~/.gem/cache", "~/.bundle/cache"}, Cmds: []Command{{Args: []string{"gem", "cleanup"}}, {Args: []string{"bundle", "clean", "--force"}}}, Tools: []Tool{{Name: "gem", InstallCmd: "brew install ruby", InstallNotes: "gem is included with Ruby installation"}, {Name: "bundle", InstallCmd: "gem install bundler"}}},
+		{Name: "php", Enabled: true, Notes: "Composer PHP cache", Paths: []string{"~/.composer/cache"}, Cmds: []Command{{Args: []string{"composer", "clear-cache"}}}, Tools: []Tool{{Name: "composer", InstallCmd: "brew install composer"}}},
+		{Name: "dotnet", Enabled: true, Notes: ".NET SDK and NuGet caches", Paths: []string{"~/.nuget/packages", "~/.dotnet/tools"}, Cmds: []Command{{Args: []string{"dotnet", "nuget", "locals", "all", "--clear"}}}, Tools: []Tool{{Name: "dotnet", InstallCmd: "brew install --cask dotnet"}}},
+		{Name: "vscode", Enabled: true, Notes: "VS Code caches and logs", Paths: []string{"~/Library/Application Support/Code/Cache", "~/Library/Application Support/Code/CachedData", "~/Library/Application Support/Code/GPUCache", "~/Library/Application Support/Code/logs"}, Cmds: []Command{}, Delete: &DeletePolicy{UnlessRunning: []string{"Code", "code"}}},
+		{Name: "jetbrains", Enabled: true, Notes: "JetBrains IDE caches (IntelliJ, PyCharm, WebStorm, etc.)", Paths: []string{"~/Library/Caches/JetBrains", "~/Library/Logs/JetBrains", "~/Library/Application Support/JetBrains/*/system/caches"}, Cmds: []Command{}, Delete: &DeletePolicy{OlderThan: "90d"}},
+		{Name: "build-tools", Enabled: true, Notes: "Compiler and build caches (ccache, bazel, Xcode)", Paths: []string{"~/.ccache", "~/.bazel-cache", "~/.cache/bazel"}, Cmds: []Command{{Args: []string{"ccache", "-C"}}}, Tools: []Tool{{Name: "ccache", InstallCmd: "brew install ccache"}}},
+		{Name: "chrome", Enabled: true, Notes: "Chrome cache (no CLI; opt-in delete policy)", Paths: []string{"~/Library/Caches/Google/Chrome", "~/Library/Application Support/Google/Chrome/*/Cache"}, Cmds: []Command{}, Delete: &DeletePolicy{UnlessRunning: []string{"Google Chrome", "chrome", "chromium"}}},
+		{Name: "macos", Enabled: false, Notes: "macOS system caches (advanced users only)", Paths: []string{"~/Library/Caches", "~/Library/Containers/com.apple.QuickLook.thumbnailcache"}, Cmds: []Command{{Args: []string{"qlmanage", "-r", "cache"}}}},
+		{Name: "flutter", Enabled: true, Notes: "Flutter and Dart caches (pub, SDK, and analysis artifacts)", Paths: []string{"~/.pub-cache", "~/.dartServer", "~/Library/Developer/flutter", "~/Library/Caches/flutter"}, Cmds: []Command{{Args: []string{"flutter", "pub", "cache", "clean", "--force"}}}, Tools: []Tool{{Name: "flutter", InstallCmd: "Install Flutter manually", InstallNotes: "For installation instructions, visit: https://docs.flutter.dev/install/manual"}}},
+		{Name: "android", Enabled: true, Notes: "Android SDK and emulator caches", Paths: []string{"~/.android/cache", "~/.android/avd", "~/Library/Android/sdk"}, Cmds: []Command{{Args: []string{"sdkmanager", "--update"}}}},
+		{Name: "android-studio", Enabled: true, Notes: "Android Studio IDE caches, logs, and indexes", Paths: []string{"~/Library/Caches/Google/AndroidStudio*", "~/Library/Logs/Google/AndroidStudio*", "~/Library/Application Support/Google/AndroidStudio*/system/caches", "~/Library/Application Support/Google/AndroidStudio*/system/index"}, Cmds: []Command{}, Delete: &DeletePolicy{OlderThan: "90d", UnlessRunning: []string{"studio"}}},
+		{Name: "terraform", Enabled: true, Notes: "Terraform plugin cache", Paths: []string{"~/.terraform.d/plugin-cache/"}, Cmds: []Command{}, Tools: []Tool{{Name: "terraform", InstallCmd: "brew install terraform"}}, Delete: &DeletePolicy{OlderThan: "90d"}},
+		{Name: "packer", Enabled: true, Notes: "Packer plugins directory", Paths: []string{"~/.packer.d/plugins"}, Cmds: []Command{}, Tools: []Tool{{Name: "packer", InstallCmd: "brew install packer"}}, Delete: &DeletePolicy{OlderThan: "90d"}},
+		{Name: "ollama", Enabled: true, Notes: "Ollama models and cache (uses official prune)", Paths: []string{"~/.ollama/models"}, Cmds: []Command{{Args: []string{"ollama", "list"}}}, Tools: []Tool{{Name: "ollama", InstallCmd: "brew install ollama"}}},
+		{Name: "home-cache", Enabled: true, Notes: "Top-level ~/.cache subdirectories (opt-in delete policy)", Paths: []string{"~/.cache/*"}, Cmds: []Command{}, Tools: []Tool{}, Delete: &DeletePolicy{OlderThan: "90d"}},
+		{Name: "pyenv", Enabled: true, Notes: "Pyenv installed versions and downloads (opt-in keep-N version policy)", Paths: []string{"~/.pyenv/versions", "~/.pyenv/cache", "~/.pyenv/plugins/python-build/share/python-build/cache"}, Cmds: []Command{}, Tools: []Tool{{Name: "pyenv", InstallCmd: "brew install pyenv", InstallNotes: "Enable the versions policy to remove unused versions, or run: pyenv uninstall <version>"}}, Versions: &VersionPolicy{Manager: "pyenv", Keep: 2}},
+		{Name: "rustup", Enabled: true, Notes: "Rustup toolchains and targets (opt-in keep-N version policy)", Paths: []string{"~/.rustup/toolchains", "~/.rustup/tmp", "~/.rustup/downloads"}, Cmds: []Command{}, Tools: []Tool{{Name: "rustup", InstallCmd: "curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh", InstallNotes: "Enable the versions policy to remove unused toolchains, or run: rustup toolchain uninstall <name>"}}, Versions: &VersionPolicy{Manager: "rustup", Keep: 2}},
+		{Name: "vscode-extensions", Enabled: true, Notes: "VS Code extensions and data under ~/.vscode (informational)", Paths: []string{"~/.vscode"}, Cmds: []Command{}, Tools: []Tool{}},
+		{Name: "rvm", Enabled: true, Notes: "RVM installed rubies and archives (opt-in keep-N version policy)", Paths: []string{"~/.rvm/rubies", "~/.rvm/archives", "~/.rvm/src"}, Cmds: []Command{{Args: []string{"rvm", "cleanup", "all"}}}, Tools: []Tool{{Name: "rvm", InstallCmd: "curl -sSL https://get.rvm.io | bash", InstallNotes: "Enable the versions policy to remove unused rubies, or run: rvm remove <ruby>"}}, Versions: &VersionPolicy{Manager: "rvm", Keep: 2}},
+		{Name: "dropbox", Enabled: true, Notes: "Dropbox metadata and state (informational only; no safe CLI clean)", Paths: []string{"~/.dropbox"}, Cmds: []Command{}, Tools: []Tool{}},
+		{Name: "cursor", Enabled: true, Notes: "Cursor editor state and cache (informational)", Paths: []string{"~/.cursor"}, Cmds: []Command{}, Tools: []Tool{}},
+		{Name: "puppeteer", Enabled: true, Notes: "Puppeteer cache", Paths: []string{"~/.cache/puppeteer"}, Cmds: []Command{{Args: puppeteerTrim}}, Tools: []Tool{{Name: "node", InstallCmd: "brew install node"}}},
 	}
-	b, err := yaml.Marshal(starter)
-	if err != nil {
-		return err
+}
+
+// puppeteerTrim removes browsers that the installed Puppeteer no longer uses.
+var puppeteerTrim = []string{"sh", "-c", "npm list -g puppeteer >/dev/null 2>&1 || npm install -g puppeteer; NODE_PATH=$(npm root -g) node -e \"const puppeteer = require('puppeteer'); puppeteer.default.trimCache().then(() => process.exit(0)).catch((e) => {console.error(e); process.exit(1);})\""}
+
+// linuxTargets is the Linux starter catalogue, using the XDG base directories.
+// Caches live under $XDG_CACHE_HOME when it is set and ~/.cache otherwise.
+// Tools without an install command get a package manager hint from --check-tools.
+func linuxTargets() []Target {
+	cache := "~/.cache"
+	if os.Getenv("XDG_CACHE_HOME") != "" {
+		cache = "$XDG_CACHE_HOME"
+	}
+	data := "~/.local/share"
+	if os.Getenv("XDG_DATA_HOME") != "" {
+		data = "$XDG_DATA_HOME"
+	}
+	config := "~/.config"
+	if os.Getenv("XDG_CONFIG_HOME") != "" {
+		config = "$XDG_CONFIG_HOME"
+	}
+	rustup := "curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh"
+	return []Target{
+		{Name: "docker", Enabled: true, Notes: "Docker caches and images (safe CLI prune only)", Paths: []string{data + "/docker", cache + "/docker"}, Cmds: []Command{{Args: []string{"docker", "builder", "prune", "-af"}}, {Args: []string{"docker", "system", "prune", "-af", "--volumes"}}}, Tools: []Tool{{Name: "docker", InstallNotes: "See https://docs.docker.com/engine/install/"}}},
+		{Name: "podman", Enabled: true, Notes: "Podman images, containers and build cache", Paths: []string{data + "/containers"}, Cmds: []Command{{Args: []string{"podman", "system", "prune", "-af"}}}, Tools: []Tool{{Name: "podman"}}},
+		{Name: "npm", Enabled: true, Notes: "npm cache", Paths: []string{"~/.npm"}, Cmds: []Command{{Args: []string{"npm", "cache", "clean", "--force"}}}, Tools: []Tool{{Name: "npm"}}},
+		{Name: "yarn", Enabled: true, Notes: "Global Yarn cache", Paths: []string{cache + "/yarn", "~/.yarn/berry/cache"}, Cmds: []Command{{Args: []string{"yarn", "cache", "clean"}}}, Tools: []Tool{{Name: "yarn", InstallCmd: "npm install -g yarn"}}},
+		{Name: "pnpm", Enabled: true, Notes: "pnpm store and cache", Paths: []string{data + "/pnpm/store", cache + "/pnpm"}, Cmds: []Command{{Args: []string{"pnpm", "store", "prune"}}}, Tools: []Tool{{Name: "pnpm", InstallCmd: "npm install -g pnpm"}}},
+		{Name: "node-versions", Enabled: true, Notes: "Node version manager (nvm)", Paths: []string{"~/.nvm/.cache", "~/.nvm/versions/node"}, Cmds: []Command{{Args: []string{"nvm", "cache", "clear"}}}, Tools: []Tool{{Name: "nvm", InstallCmd: "curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/v0.40.3/install.sh | bash", InstallNotes: "After installation, restart your terminal or run: source ~/.bashrc", CheckPath: "~/.nvm/nvm.sh"}}, Versions: &VersionPolicy{Manager: "nvm", Keep: 2}},
+		{Name: "expo", Enabled: true, Notes: "Expo and React Native caches", Paths: []string{"~/.expo", cache + "/expo"}, Cmds: []Command{{Args: []string{"expo", "start", "-c"}, Interaction: InteractionTTY}}, Tools: []Tool{{Name: "expo", InstallCmd: "npm install -g expo-cli"}}},
+		{Name: "go", Enabled: true, Notes: "Go build & module caches", Paths: []string{cache + "/go-build", "$GOMODCACHE/cache", "$GOPATH/pkg/mod/cache"}, Cmds: []Command{{Args: []string{"go", "clean", "-cache", "-testcache", "-modcache"}}}, Tools: []Tool{{Name: "go", InstallNotes: "See https://go.dev/doc/install"}}},
+		{Name: "rust", Enabled: true, Notes: "Rust registry and build caches (requires cargo-cache: cargo install cargo-cache)", Paths: []string{"~/.cargo/registry", "~/.cargo/git"}, Cmds: []Command{{Args: []string{"cargo", "cache", "-a"}}}, Tools: []Tool{{Name: "cargo", InstallCmd: rustup}, {Name: "cargo-cache", InstallCmd: "cargo install cargo-cache", InstallNotes: "Install this after cargo is installed"}}},
+		{Name: "python", Enabled: true, Notes: "pip and pipenv caches", Paths: []string{cache + "/pip", data + "/virtualenvs"}, Cmds: []Command{{Args: []string{"pip", "cache", "purge"}}}, Tools: []Tool{{Name: "pip", InstallNotes: "pip is included with Python installation"}}},
+		{Name: "python-poetry", Enabled: true, Notes: "Poetry package manager cache", Paths: []string{cache + "/pypoetry"}, Cmds: []Command{{Args: []string{"poetry", "cache", "clear", "--all", "pypi", "--no-interaction"}}}, Tools: []Tool{{Name: "poetry", InstallCmd: "curl -sSL https://install.python-poetry.org | python3 -"}}},
+		{Name: "python-uv", Enabled: true, Notes: "uv Python package installer cache", Paths: []string{cache + "/uv"}, Cmds: []Command{{Args: []string{"uv", "cache", "clean"}}}, Tools: []Tool{{Name: "uv", InstallCmd: "curl -LsSf https://astral.sh/uv/install.sh | sh", InstallNotes: "uv is a fast Python package installer"}}},
+		{Name: "conda", Enabled: true, Notes: "Conda package and cache cleanup", Paths: []string{"~/.conda/pkgs", "~/miniconda3/pkgs"}, Cmds: []Command{{Args: []string{"conda", "clean", "-a", "-y"}}}, Tools: []Tool{{Name: "conda", InstallNotes: "See https://docs.conda.io/en/latest/miniconda.html"}}},
+		{Name: "maven", Enabled: true, Notes: "Maven local repo purge (safe via plugin)", Paths: []string{"~/.m2/repository"}, Cmds: []Command{{Args: []string{"mvn", "-q", "dependency:purge-local-repository", "-DreResolve=false"}}}, Tools: []Tool{{Name: "mvn"}}},
+		{Name: "gradle", Enabled: true, Notes: "Gradle build caches and wrappers", Paths: []string{"~/.gradle/caches", "~/.gradle/wrapper/dists"}, Cmds: []Command{}, Tools: []Tool{{Name: "gradle"}}, Delete: &DeletePolicy{OlderThan: "30d"}, Versions: &VersionPolicy{Manager: "gradle", Keep: 2}},
+		{Name: "ruby", Enabled: true, Notes: "Ruby and Bundler caches", Paths: []string{"~/.gem/cache", "~/.bundle/cache", "~/.local/share/gem"}, Cmds: []Command{{Args: []string{"gem", "cleanup"}}, {Args: []string{"bundle", "clean", "--force"}}}, Tools: []Tool{{Name: "gem", InstallNotes: "gem is included with Ruby installation"}, {Name: "bundle", InstallCmd: "gem install bundler"}}},
+		{Name: "php", Enabled: true, Notes: "Composer PHP cache", Paths: []string{cache + "/composer", "~/.composer/cache"}, Cmds: []Command{{Args: []string{"composer", "clear-cache"}}}, Tools: []Tool{{Name: "composer"}}},
+		{Name: "dotnet", Enabled: true, Notes: ".NET SDK and NuGet caches", Paths: []string{"~/.nuget/packages", data + "/NuGet", "~/.dotnet/tools"}, Cmds: []Command{{Args: []string{"dotnet", "nuget", "locals", "all", "--clear"}}}, Tools: []Tool{{Name: "dotnet", InstallNotes: "See https://learn.microsoft.com/dotnet/core/install/linux"}}},
+		{Name: "vscode", Enabled: true, Notes: "VS Code caches and logs", Paths: []string{config + "/Code/Cache", config + "/Code/CachedData", config + "/Code/GPUCache", config + "/Code/logs"}, Cmds: []Command{}, Delete: &DeletePolicy{UnlessRunning: []string{"Code", "code"}}},
+		{Name: "jetbrains", Enabled: true, Notes: "JetBrains IDE caches, indexes and logs (IntelliJ, PyCharm, GoLand, etc.)", Paths: []string{cache + "/JetBrains"}, Cmds: []Command{}, Delete: &DeletePolicy{OlderThan: "90d"}},
+		{Name: "build-tools", Enabled: true, Notes: "Compiler and build caches (ccache, sccache, bazel)", Paths: []string{cache + "/ccache", "~/.ccache", cache + "/sccache", cache + "/bazel"}, Cmds: []Command{{Args: []string{"ccache", "-C"}}}, Tools: []Tool{{Name: "ccache"}}},
+		{Name: "chrome", Enabled: true, Notes: "Chrome and Chromium caches (no CLI; opt-in delete policy)", Paths: []string{cache + "/google-chrome", cache + "/chromium"}, Cmds: []Command{}, Delete: &DeletePolicy{UnlessRunning: []string{"Google Chrome", "chrome", "chromium"}}},
+		{Name: "thumbnails", Enabled: true, Notes: "Desktop file manager thumbnails (informational only)", Paths: []string{cache + "/thumbnails"}, Cmds: []Command{}},
+		{Name: "flutter", Enabled: true, Notes: "Flutter and Dart caches (pub, SDK, and analysis artifacts)", Paths: []string{"~/.pub-cache", "~/.dartServer", cache + "/flutter"}, Cmds: []Command{{Args: []string{"flutter", "pub", "cache", "clean", "--force"}}}, Tools: []Tool{{Name: "flutter", InstallCmd: "Install Flutter manually", InstallNotes: "For installation instructions, visit: https://docs.flutter.dev/install/manual"}}},
+		{Name: "android", Enabled: true, Notes: "Android SDK and emulator caches", Paths: []string{"~/.android/cache", "~/.android/avd", "~/Android/Sdk"}, Cmds: []Command{{Args: []string{"sdkmanager", "--update"}}}},
+		{Name: "android-studio", Enabled: true, Notes: "Android Studio IDE caches, logs, and indexes", Paths: []string{cache + "/Google/AndroidStudio*"}, Cmds: []Command{}, Delete: &DeletePolicy{OlderThan: "90d", UnlessRunning: []string{"studio"}}},
+		{Name: "terraform", Enabled: true, Notes: "Terraform plugin cache", Paths: []string{"~/.terraform.d/plugin-cache/"}, Cmds: []Command{}, Tools: []Tool{{Name: "terraform"}}, Delete: &DeletePolicy{OlderThan: "90d"}},
+		{Name: "packer", Enabled: true, Notes: "Packer plugins directory", Paths: []string{config + "/packer/plugins", "~/.packer.d/plugins"}, Cmds: []Command{}, Tools: []Tool{{Name: "packer"}}, Delete: &DeletePolicy{OlderThan: "90d"}},
+		{Name: "ollama", Enabled: true, Notes: "Ollama models and cache (uses official prune)", Paths: []string{"~/.ollama/models"}, Cmds: []Command{{Args: []string{"ollama", "list"}}}, Tools: []Tool{{Name: "ollama", InstallCmd: "curl -fsSL https://ollama.com/install.sh | sh"}}},
+		{Name: "home-cache", Enabled: true, Notes: "Top-level cache directory subdirectories (opt-in delete policy)", Paths: []string{cache + "/*"}, Cmds: []Command{}, Tools: []Tool{}, Delete: &DeletePolicy{OlderThan: "90d"}},
+		{Name: "pyenv", Enabled: true, Notes: "Pyenv installed versions and downloads (opt-in keep-N version policy)", Paths: []string{"~/.pyenv/versions", "~/.pyenv/cache", "~/.pyenv/plugins/python-build/share/python-build/cache"}, Cmds: []Command{}, Tools: []Tool{{Name: "pyenv", InstallCmd: "curl https://pyenv.run | bash", InstallNotes: "Enable the versions policy to remove unused versions, or run: pyenv uninstall <version>"}}, Versions: &VersionPolicy{Manager: "pyenv", Keep: 2}},
+		{Name: "rustup", Enabled: true, Notes: "Rustup toolchains and targets (opt-in keep-N version policy)", Paths: []string{"~/.rustup/toolchains", "~/.rustup/tmp", "~/.rustup/downloads"}, Cmds: []Command{}, Tools: []Tool{{Name: "rustup", InstallCmd: rustup, InstallNotes: "Enable the versions policy to remove unused toolchains, or run: rustup toolchain uninstall <name>"}}, Versions: &VersionPolicy{Manager: "rustup", Keep: 2}},
+		{Name: "vscode-extensions", Enabled: true, Notes: "VS Code extensions and data under ~/.vscode (informational)", Paths: []string{"~/.vscode"}, Cmds: []Command{}, Tools: []Tool{}},
+		{Name: "rvm", Enabled: true, Notes: "RVM installed rubies and archives (opt-in keep-N version policy)", Paths: []string{"~/.rvm/rubies", "~/.rvm/archives", "~/.rvm/src"}, Cmds: []Command{{Args: []string{"rvm", "cleanup", "all"}}}, Tools: []Tool{{Name: "rvm", InstallCmd: "curl -sSL https://get.rvm.io | bash", InstallNotes: "Enable the versions policy to remove unused rubies, or run: rvm remove <ruby>"}}, Versions: &VersionPolicy{Manager: "rvm", Keep: 2}},
+		{Name: "cursor", Enabled: true, Notes: "Cursor editor caches and logs (informational)", Paths: []string{config + "/Cursor/Cache", config + "/Cursor/CachedData", config + "/Cursor/logs"}, Cmds: []Command{}, Tools: []Tool{}},
+		{Name: "puppeteer", Enabled: true, Notes: "Puppeteer cache", Paths: []string{cache + "/puppeteer"}, Cmds: []Command{{Args: puppeteerTrim}}, Tools: []Tool{{Name: "node"}}},
+		{Name: "trash", Enabled: false, Notes: "Desktop trash (informational only; empty it from your file manager)", Paths: []string{data + "/Trash"}, Cmds: []Command{}},
 	}
-	return os.WriteFile(path, b, 0o644)
 }
 
+// loadConfig reads the config at path and applies each target's variant for the host OS.
 func loadConfig(path string) (*Config, error) {
-	b, err := os.ReadFile(path)
+	cfg, err := cachecore.LoadConfig[Config](path)
 	if err != nil {
 		return nil, err
 	}
-	var cfg Config
-	if err := yaml.Unmarshal(b, &cfg); err != nil {
-		return nil, err
+	for i := range cfg.Targets {
+		cfg.Targets[i] = cfg.Targets[i].forOS(runtime.GOOS)
 	}
-	return &cfg, nil
+	return cfg, nil
 }
 
 // ----- Tool checking -----
@@ -598,47 +684,861 @@ func getInstallCommand(tool Tool) string {
 
 // ----- Command runner -----
 
-func runCmd(cmd []string) CmdResult {
-	res := CmdResult{Cmd: cmd}
-	if len(cmd) == 0 {
+// Interaction modes of a Command.
+const (
+	InteractionBatch   = "batch"   // stdin is empty, so a command that asks anything reads end of file
+	InteractionPrompts = "prompts" // the expected prompts are answered, then stdin is closed
+	InteractionTTY     = "tty"     // the command needs the user's terminal; skipped when there is none
+)
+
+// defaultCmdTimeout bounds commands that set no timeout; options.commandTimeout overrides it.
+var defaultCmdTimeout = 30 * time.Minute
+
+// stdinIsTerminal reports whether the user can answer a command's prompts; tests replace it.
+var stdinIsTerminal = func() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Command is a clean command. In the config it is either a plain argument list,
+// e.g. [brew, cleanup, -s], or a mapping that also declares how the command
+// takes input and how long it may run:
+//
+//	cmds:
+//	  - run: [poetry, cache, clear, --all, pypi]
+//	    prompts:
+//	      - {expect: "(yes/no)", respond: "yes"}
+//	    timeout: 5m
+type Command struct {
+	Args        []string `yaml:"run"`
+	Interaction string   `yaml:"interaction,omitempty"` // batch (default), prompts (default when prompts are set) or tty
+	Prompts     []Prompt `yaml:"prompts,omitempty"`
+	Timeout     string   `yaml:"timeout,omitempty"` // e.g. 10m; killed when exceeded (default: options.commandTimeout or 30m)
+}
+
+// Prompt is a question a command is expected to ask and the line to answer it with.
+type Prompt struct {
+	Expect  string `yaml:"expect"`  // text the command prints when it asks
+	Respond string `yaml:"respond"` // line written to its stdin in reply
+}
+
+// UnmarshalYAML accepts a plain argument list as well as the mapping form.
+func (c *Command) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		return node.Decode(&c.Args)
+	}
+	type plain Command
+	return node.Decode((*plain)(c))
+}
+
+// MarshalYAML writes a command with no settings as a plain argument list.
+func (c Command) MarshalYAML() (interface{}, error) {
+	if c.Interaction == "" && len(c.Prompts) == 0 && c.Timeout == "" {
+		return c.Args, nil
+	}
+	type plain Command
+	return plain(c), nil
+}
+
+// mode returns the command's interaction mode.
+func (c Command) mode() string {
+	switch {
+	case c.Interaction != "":
+		return c.Interaction
+	case len(c.Prompts) > 0:
+		return InteractionPrompts
+	}
+	return InteractionBatch
+}
+
+// promptResponder answers a command's expected prompts in order as they appear
+// in its output, and closes stdin once the last one is answered so that an
+// unexpected question reads end of file instead of blocking.
+type promptResponder struct {
+	mu      sync.Mutex
+	stdin   io.WriteCloser
+	prompts []Prompt
+	recent  []byte // output since the last answer, at most maxPromptWindow bytes
+}
+
+const maxPromptWindow = 4096
+
+// writer returns a writer that copies to out and watches for prompts.
+func (r *promptResponder) writer(out io.Writer) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		n, err := out.Write(p)
+		r.observe(p)
+		return n, err
+	})
+}
+
+func (r *promptResponder) observe(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recent = append(r.recent, p...)
+	if len(r.recent) > maxPromptWindow {
+		r.recent = r.recent[len(r.recent)-maxPromptWindow:]
+	}
+	for len(r.prompts) > 0 && strings.Contains(string(r.recent), r.prompts[0].Expect) {
+		_, _ = io.WriteString(r.stdin, r.prompts[0].Respond+"\n")
+		r.prompts = r.prompts[1:]
+		r.recent = r.recent[:0]
+		if len(r.prompts) == 0 {
+			_ = r.stdin.Close()
+		}
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// runCmd runs a clean command according to its interaction mode and kills it
+// if it runs past its timeout.
+func runCmd(cmd Command) CmdResult {
+	res := CmdResult{Cmd: cmd.Args}
+	if len(cmd.Args) == 0 {
 		res.Error = "empty command"
 		return res
 	}
-	if _, err := exec.LookPath(cmd[0]); err != nil {
+	if _, err := exec.LookPath(cmd.Args[0]); err != nil {
 		res.Found = false
 		res.Error = "not found"
 		return res
 	}
 	res.Found = true
-	c := exec.Command(cmd[0], cmd[1:]...)
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
 
-	// Create a pipe to write to stdin
-	stdin, err := c.StdinPipe()
-	if err != nil {
-		res.Error = fmt.Sprintf("stdin pipe error: %v", err)
+	timeout := defaultCmdTimeout
+	if cmd.Timeout != "" {
+		d, err := cachecore.ParseAge(cmd.Timeout)
+		if err != nil || d == 0 {
+			res.Error = fmt.Sprintf("invalid timeout %q", cmd.Timeout)
+			return res
+		}
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, cmd.Args[0], cmd.Args[1:]...)
+	// Output pipes held open by children of a killed command must not block Wait
+	c.WaitDelay = 5 * time.Second
+	var stdout, stderr tailBuffer
+	c.Stdout = io.MultiWriter(cmdOutput, &stdout)
+	c.Stderr = io.MultiWriter(os.Stderr, &stderr)
+
+	switch mode := cmd.mode(); mode {
+	case InteractionBatch:
+		// c.Stdin stays nil: the command reads from the null device
+	case InteractionTTY:
+		if !stdinIsTerminal() {
+			res.Error = "skipped: needs a terminal to answer its prompts"
+			return res
+		}
+		// The terminal itself, so the command can prompt. Stdout still goes to
+		// cmdOutput, which is stderr under --json so the report stays clean
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, cmdOutput, os.Stderr
+	case InteractionPrompts:
+		stdin, err := c.StdinPipe()
+		if err != nil {
+			res.Error = fmt.Sprintf("stdin pipe error: %v", err)
+			return res
+		}
+		r := &promptResponder{stdin: stdin, prompts: cmd.Prompts}
+		c.Stdout = r.writer(c.Stdout)
+		c.Stderr = r.writer(c.Stderr)
+	default:
+		res.Error = fmt.Sprintf("unknown interaction %q (use %s, %s or %s)", mode, InteractionBatch, InteractionPrompts, InteractionTTY)
 		return res
 	}
 
-	// Start the command
-	if err := c.Start(); err != nil {
+	start := time.Now()
+	err := c.Run()
+	end := time.Now()
+	res.Start, res.End, res.DurationMS = &start, &end, end.Sub(start).Milliseconds()
+	if c.ProcessState != nil && c.ProcessState.ExitCode() >= 0 {
+		code := c.ProcessState.ExitCode()
+		res.ExitCode = &code
+	}
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+	res.ReclaimedBytes = parseReclaimed(cmd.Args[0], res.Stdout+"\n"+res.Stderr)
+	if err != nil {
 		res.Error = err.Error()
-		return res
+		if ctx.Err() == context.DeadlineExceeded {
+			res.Error = fmt.Sprintf("timed out after %s and was killed", timeout)
+		}
 	}
+	return res
+}
 
-	// Send 'y' to confirm any interactive prompts
-	_, _ = stdin.Write([]byte("y\n"))
-	if err := stdin.Close(); err != nil {
-		res.Error = fmt.Sprintf("stdin close error: %v", err)
-		return res
+// cmdOutput receives the output of clean commands; --json sends it to stderr
+// so that stdout holds only the report.
+var cmdOutput io.Writer = os.Stdout
+
+// cmdTailBytes is how much of each output stream a CmdResult keeps.
+const cmdTailBytes = 4096
+
+// tailBuffer keeps the last cmdTailBytes written to it.
+type tailBuffer struct {
+	buf       []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - cmdTailBytes; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+		b.truncated = true
 	}
+	return len(p), nil
+}
 
-	// Wait for the command to complete
-	if err := c.Wait(); err != nil {
-		res.Error = err.Error()
+// String returns the kept output, starting with "..." if earlier output was dropped.
+func (b *tailBuffer) String() string {
+	if b.truncated {
+		return "..." + string(b.buf)
+	}
+	return string(b.buf)
+}
+
+// reclaimedPatterns match the summary lines in which commands report the space
+// they freed, by command name.
+var reclaimedPatterns = map[string]*regexp.Regexp{
+	// "Total reclaimed space: 1.2GB" (system, image and volume prune) and "Total:  1.2GB" (builder prune)
+	"docker": regexp.MustCompile(`(?m)^Total(?: reclaimed space)?:\s+([\d.]+\s*[a-zA-Z]+)`),
+	"podman": regexp.MustCompile(`(?m)^Total reclaimed space:\s+([\d.]+\s*[a-zA-Z]+)`),
+	// "This operation has freed approximately 1.2GB of disk space."
+	"brew": regexp.MustCompile(`freed approximately ([\d.]+\s*[a-zA-Z]+) of disk space`),
+}
+
+// parseReclaimed returns the space a command reports having freed in its
+// output, summed over every summary line, or 0 if it does not say.
+func parseReclaimed(name, output string) int64 {
+	re, ok := reclaimedPatterns[filepath.Base(name)]
+	if !ok {
+		return 0
+	}
+	var total int64
+	for _, m := range re.FindAllStringSubmatch(output, -1) {
+		if n, ok := parseHumanSize(m[1]); ok {
+			total += n
+		}
+	}
+	return total
+}
+
+// reportedReclaimed sums the space the commands in results report having freed.
+func reportedReclaimed(results map[string][]CmdResult) int64 {
+	var total int64
+	for _, rs := range results {
+		for _, r := range rs {
+			total += r.ReclaimedBytes
+		}
+	}
+	return total
+}
+
+// ----- Delete policies -----
+
+// processRunning reports whether a process with exactly this name is running;
+// tests replace it.
+var processRunning = func(name string) (bool, error) {
+	err := exec.Command("pgrep", "-x", name).Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("pgrep: %w", err)
+	}
+	return true, nil
+}
+
+// validate checks the policy's age and globs.
+func (p *DeletePolicy) validate() error {
+	if _, err := cachecore.ParseAge(p.OlderThan); err != nil {
+		return err
+	}
+	for _, g := range append(append([]string{}, p.Include...), p.Exclude...) {
+		if _, err := filepath.Match(g, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", g, err)
+		}
+	}
+	return nil
+}
+
+// blocked returns why nothing may be deleted right now, or "" if deletion may
+// go ahead. A process check that fails blocks deletion too.
+func (p *DeletePolicy) blocked() string {
+	for _, name := range p.UnlessRunning {
+		running, err := processRunning(name)
+		if err != nil {
+			return fmt.Sprintf("cannot check whether %s is running: %v", name, err)
+		}
+		if running {
+			return name + " is running"
+		}
+	}
+	return ""
+}
+
+// wants reports whether the include and exclude globs select an entry name.
+func (p *DeletePolicy) wants(name string) bool {
+	for _, g := range p.Exclude {
+		if ok, _ := filepath.Match(g, name); ok {
+			return false
+		}
+	}
+	if len(p.Include) == 0 {
+		return true
+	}
+	for _, g := range p.Include {
+		if ok, _ := filepath.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDeleteRoot refuses to delete entries of the filesystem root, the home
+// directory or any directory above it, whatever a config's paths expand to.
+func checkDeleteRoot(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("refusing to delete under relative path %s", dir)
+	}
+	dir = filepath.Clean(dir)
+	home, _ := os.UserHomeDir()
+	if dir == filepath.Dir(dir) || (home != "" && (dir == home || strings.HasPrefix(home, dir+string(filepath.Separator)))) {
+		return fmt.Errorf("refusing to delete under %s", dir)
+	}
+	return nil
+}
+
+// deleteEntries expands a target's paths and returns the entries its policy
+// selects: the children of each directory, or the path itself for a file.
+// Excluded paths are never selected.
+func deleteEntries(t Target) ([]string, error) {
+	var entries []string
+	seen := map[string]bool{}
+	add := func(p string, isDir bool) {
+		if !seen[p] && t.Delete.wants(filepath.Base(p)) && !excluder.Skip(p, isDir) {
+			seen[p] = true
+			entries = append(entries, p)
+		}
+	}
+	for _, pattern := range t.Paths {
+		matches, err := expandGlobs(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if err := checkDeleteRoot(m); err != nil {
+				return nil, err
+			}
+			fi, err := os.Lstat(m)
+			if err != nil {
+				continue
+			}
+			if !fi.IsDir() {
+				add(m, false)
+				continue
+			}
+			children, err := os.ReadDir(m)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range children {
+				add(filepath.Join(m, c.Name()), c.IsDir())
+			}
+		}
+	}
+	return entries, nil
+}
+
+// planDeletes lists, for each target with an enabled delete policy, the entries
+// it would remove: those selected by its globs with nothing modified within
+// olderThan of now. Targets that are blocked or misconfigured are left out with
+// a warning.
+func planDeletes(targets []Target, now time.Time) (map[string][]DeleteResult, []string) {
+	plans := map[string][]DeleteResult{}
+	var warnings []string
+	for _, t := range targets {
+		if t.Delete == nil || !t.Delete.Enabled {
+			continue
+		}
+		if err := t.Delete.validate(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("[%s] delete policy skipped: %v", t.Name, err))
+			continue
+		}
+		if reason := t.Delete.blocked(); reason != "" {
+			warnings = append(warnings, fmt.Sprintf("[%s] delete policy skipped: %s", t.Name, reason))
+			continue
+		}
+		entries, err := deleteEntries(t)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("[%s] delete policy skipped: %v", t.Name, err))
+			continue
+		}
+		minAge, _ := cachecore.ParseAge(t.Delete.OlderThan)
+		sizer := newSizer()
+		if minAge > 0 {
+			// The index keeps a directory's newest-file time until the directory itself
+			// changes, so files rewritten in place would make an entry in use look old
+			sizer.Index = nil
+		}
+		sized, errs := sizer.InspectAll(entries)
+		for i, f := range sized {
+			if errs[i] != nil {
+				warnings = append(warnings, fmt.Sprintf("[%s] not deleting %s: %v", t.Name, f.Path, errs[i]))
+				continue
+			}
+			if minAge > 0 && now.Sub(f.ModMax) < minAge {
+				continue
+			}
+			plans[t.Name] = append(plans[t.Name], DeleteResult{Path: f.Path, SizeBytes: f.SizeBytes, ModMax: f.ModMax})
+		}
+	}
+	return plans, warnings
+}
+
+// runDeletes removes the planned entries of t, checking its processes again
+// first, and returns the plan with the outcome of each entry.
+func runDeletes(t Target, plan []DeleteResult) []DeleteResult {
+	out := append([]DeleteResult{}, plan...)
+	reason := t.Delete.blocked()
+	for i := range out {
+		if reason != "" {
+			out[i].Error = "skipped: " + reason
+			continue
+		}
+		if err := os.RemoveAll(out[i].Path); err != nil {
+			out[i].Error = err.Error()
+			continue
+		}
+		out[i].Deleted = true
+	}
+	return out
+}
+
+// deleteSummary describes a delete plan for the summary table, e.g.
+// "delete 3 entries older than 30d (1.2 GB)".
+func deleteSummary(p *DeletePolicy, plan []DeleteResult) string {
+	var total int64
+	for _, d := range plan {
+		total += d.SizeBytes
+	}
+	noun := "entries"
+	if len(plan) == 1 {
+		noun = "entry"
+	}
+	age := ""
+	if p.OlderThan != "" && p.OlderThan != "0" {
+		age = " older than " + p.OlderThan
+	}
+	return fmt.Sprintf("delete %d %s%s (%s)", len(plan), noun, age, human(total))
+}
+
+// ----- Version retention -----
+
+// versionManager describes where a version manager installs versions and how
+// projects pin them.
+type versionManager struct {
+	dir       string                  // suffix of the target path holding one directory per installed version
+	files     []string                // project files that pin a version
+	globals   []string                // the manager's default-version files, relative to the versions directory
+	prefix    string                  // added to references that start with a digit, e.g. "v" for nvm
+	seps      string                  // a reference also matches versions it prefixes up to one of these
+	uninstall func(v string) []string // command that removes version v; nil removes its directory
+	inUse     func(path string) string
+}
+
+var versionManagers = map[string]versionManager{
+	"pyenv": {
+		dir: "versions", files: []string{".python-version"}, globals: []string{"../version"}, seps: ".",
+		uninstall: func(v string) []string { return []string{"pyenv", "uninstall", "-f", v} },
+		inUse: func(path string) string {
+			if envs, _ := os.ReadDir(filepath.Join(path, "envs")); len(envs) > 0 {
+				return "has virtualenvs"
+			}
+			return ""
+		},
+	},
+	"rustup": {
+		dir: "toolchains", files: []string{"rust-toolchain.toml", "rust-toolchain"}, globals: []string{"../settings.toml"}, seps: "-",
+		uninstall: func(v string) []string { return []string{"rustup", "toolchain", "uninstall", v} },
+	},
+	"nvm": {
+		dir: "versions/node", files: []string{".nvmrc"}, globals: []string{"../../alias/default"}, prefix: "v", seps: ".",
+		uninstall: func(v string) []string {
+			return []string{"bash", "-c", `. "${NVM_DIR:-$HOME/.nvm}/nvm.sh" && nvm uninstall "$1"`, "nvm", v}
+		},
+	},
+	"rvm": {
+		dir: "rubies", files: []string{".ruby-version"}, globals: []string{"../config/alias"}, prefix: "ruby-", seps: ".-",
+		uninstall: func(v string) []string { return []string{"rvm", "remove", v} },
+	},
+	"gradle": {dir: "wrapper/dists", files: []string{"gradle-wrapper.properties"}},
+}
+
+// VersionPolicy lets --clean remove versions a version manager installed that
+// no project under the source roots pins, beyond the Keep most recent ones.
+type VersionPolicy struct {
+	Enabled bool   `yaml:"enabled"`
+	Manager string `yaml:"manager"` // pyenv, rustup, nvm, rvm or gradle
+	Keep    int    `yaml:"keep"`    // most recently installed versions kept even if no project pins them
+}
+
+// VersionResult is an installed version found by a version policy, and
+// whether it is kept or removed.
+type VersionResult struct {
+	Version   string    `json:"version"`
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size_bytes"`
+	Installed time.Time `json:"installed"`      // Modification time of the version's directory
+	Keep      string    `json:"keep,omitempty"` // Why it is kept; empty if it is to be removed
+	Removed   bool      `json:"removed"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// versionRef is a version named by a project or default-version file.
+type versionRef struct {
+	version, source string
+}
+
+var (
+	toolchainKey  = regexp.MustCompile(`(?m)^\s*(?:channel|default_toolchain)\s*=\s*"([^"]+)"`)
+	gradleDistURL = regexp.MustCompile(`(?m)^\s*distributionUrl\s*=.*/(gradle-[^/\s]+)\.zip\s*$`)
+)
+
+// parseVersionFile returns the versions a pin file names: every line of a
+// .python-version-style file, the channel of a rust-toolchain.toml, the default
+// and per-directory overrides of rustup's settings.toml, the default of an rvm
+// alias file or the distribution of a gradle-wrapper.properties.
+func parseVersionFile(name string, data []byte) []string {
+	text := string(data)
+	switch {
+	case name == "gradle-wrapper.properties":
+		if m := gradleDistURL.FindStringSubmatch(text); m != nil {
+			return []string{m[1]}
+		}
+		return nil
+	case name == "settings.toml":
+		var out []string
+		if m := toolchainKey.FindStringSubmatch(text); m != nil {
+			out = append(out, m[1])
+		}
+		// [overrides] maps directories to toolchains: "/path/to/project" = "nightly"
+		section := ""
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "[") {
+				section = line
+				continue
+			}
+			if _, v, ok := strings.Cut(line, "="); ok && section == "[overrides]" {
+				if v = strings.Trim(strings.TrimSpace(v), `"`); v != "" {
+					out = append(out, v)
+				}
+			}
+		}
+		return out
+	case strings.Contains(text, "="):
+		if m := toolchainKey.FindStringSubmatch(text); m != nil {
+			return []string{m[1]}
+		}
+		var out []string
+		for _, line := range strings.Split(text, "\n") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(line), "default="); ok {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && line != "system" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// versionMatches reports whether ref names version, either exactly or as a
+// prefix ending at one of the manager's separators ("3.11" names "3.11.4").
+func (m versionManager) versionMatches(version, ref string) bool {
+	if ref != "" && ref[0] >= '0' && ref[0] <= '9' {
+		ref = m.prefix + ref
+	}
+	if version == ref {
+		return true
+	}
+	rest, ok := strings.CutPrefix(version, ref)
+	return ok && rest != "" && strings.ContainsRune(m.seps, rune(rest[0]))
+}
+
+// scanVersionRefs walks the source roots for the pin files of every manager
+// and returns their references by manager. Hidden directories, node_modules
+// and excluded paths are not searched.
+func scanVersionRefs(roots []string) map[string][]versionRef {
+	owners := map[string][]string{}
+	for name, m := range versionManagers {
+		for _, f := range m.files {
+			owners[f] = append(owners[f], name)
+		}
+	}
+	refs := map[string][]versionRef{}
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if p != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" || excluder.Skip(p, true)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			managers := owners[d.Name()]
+			if len(managers) == 0 {
+				return nil
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return nil
+			}
+			for _, v := range parseVersionFile(d.Name(), data) {
+				for _, name := range managers {
+					refs[name] = append(refs[name], versionRef{version: v, source: p})
+				}
+			}
+			return nil
+		})
+	}
+	return refs
+}
+
+// sourceRoots returns the directories searched for pin files: --source-root
+// if given, otherwise sourceRoots from the config.
+func sourceRoots(cfg *Config) []string {
+	roots := []string(*flagSourceRoot)
+	if len(roots) == 0 {
+		roots = cfg.Options.SourceRoots
+	}
+	out := make([]string, 0, len(roots))
+	for _, r := range roots {
+		out = append(out, expand(r))
+	}
+	return out
+}
+
+// planVersions lists the installed versions of each target with an enabled
+// version policy, newest first. A version is kept when a pin file under roots
+// or the manager's default names it, when it is among the policy's Keep most
+// recently installed, or when it is otherwise in use; the rest are marked for
+// removal. Without source roots nothing is planned, since every pinned version
+// would look unused.
+func planVersions(targets []Target, roots []string) (map[string][]VersionResult, []string) {
+	plans := map[string][]VersionResult{}
+	var warnings []string
+	var refs map[string][]versionRef
+	for _, t := range targets {
+		p := t.Versions
+		if p == nil || !p.Enabled {
+			continue
+		}
+		m, ok := versionManagers[p.Manager]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("[%s] version policy skipped: unknown manager %q", t.Name, p.Manager))
+			continue
+		}
+		if len(roots) == 0 {
+			warnings = append(warnings, fmt.Sprintf("[%s] version policy skipped: no source roots to look for pinned versions in (set sourceRoots or --source-root)", t.Name))
+			continue
+		}
+		if refs == nil {
+			refs = scanVersionRefs(roots)
+		}
+		dir, err := versionsDir(t, m)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("[%s] version policy skipped: %v", t.Name, err))
+			continue
+		}
+		if dir == "" {
+			continue
+		}
+		plan, warns, err := planManager(m, dir, refs[p.Manager], p.Keep)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("[%s] version policy skipped: %v", t.Name, err))
+			continue
+		}
+		for _, w := range warns {
+			warnings = append(warnings, fmt.Sprintf("[%s] %s", t.Name, w))
+		}
+		plans[t.Name] = plan
+	}
+	return plans, warnings
+}
+
+// versionsDir returns the target path that holds the manager's versions, or ""
+// if none of the target's paths does or it does not exist.
+func versionsDir(t Target, m versionManager) (string, error) {
+	for _, pattern := range t.Paths {
+		matches, err := expandGlobs(pattern)
+		if err != nil {
+			return "", err
+		}
+		for _, p := range matches {
+			if strings.HasSuffix(filepath.ToSlash(filepath.Clean(p)), "/"+m.dir) {
+				return p, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// planManager decides which versions installed in dir to keep. Versions that
+// cannot be sized are still planned, with a warning.
+func planManager(m versionManager, dir string, projectRefs []versionRef, keep int) ([]VersionResult, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var plan []VersionResult
+	for _, e := range entries {
+		// Symlinked entries (linked toolchains, pyenv virtualenv aliases) are not installs
+		if !e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		plan = append(plan, VersionResult{Version: e.Name(), Path: filepath.Join(dir, e.Name()), Installed: info.ModTime()})
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].Installed.After(plan[j].Installed) })
+
+	refs := append([]versionRef{}, projectRefs...)
+	for _, g := range m.globals {
+		path := filepath.Join(dir, g)
+		if data, err := os.ReadFile(path); err == nil {
+			for _, v := range parseVersionFile(filepath.Base(path), data) {
+				refs = append(refs, versionRef{version: v, source: path})
+			}
+		}
+	}
+	for _, r := range refs {
+		version := r.version
+		// A reference to a symlink in dir (e.g. a pyenv virtualenv) keeps the version it lives in
+		if target, err := filepath.EvalSymlinks(filepath.Join(dir, version)); err == nil {
+			if rel, err := filepath.Rel(dir, target); err == nil && !strings.HasPrefix(rel, "..") {
+				version = strings.Split(filepath.ToSlash(rel), "/")[0]
+			}
+		}
+		for i := range plan {
+			if plan[i].Keep == "" && m.versionMatches(plan[i].Version, version) {
+				plan[i].Keep = "pinned by " + r.source
+			}
+		}
+	}
+	for i := range plan {
+		switch {
+		case plan[i].Keep != "":
+		case i < keep:
+			plan[i].Keep = "recently installed"
+		case m.inUse != nil:
+			plan[i].Keep = m.inUse(plan[i].Path)
+		}
+	}
+
+	paths := make([]string, len(plan))
+	for i, v := range plan {
+		paths[i] = v.Path
+	}
+	var warnings []string
+	sized, errs := newSizer().InspectAll(paths)
+	for i, f := range sized {
+		if errs[i] != nil {
+			warnings = append(warnings, fmt.Sprintf("could not size %s: %v", f.Path, errs[i]))
+			continue
+		}
+		plan[i].SizeBytes = f.SizeBytes
+	}
+	return plan, warnings, nil
+}
+
+// removeVersions removes the versions of t's plan that are not kept, with the
+// manager's uninstall command or by deleting the directory, and returns the
+// plan with the outcome of each.
+func removeVersions(t Target, plan []VersionResult) []VersionResult {
+	m := versionManagers[t.Versions.Manager]
+	out := append([]VersionResult{}, plan...)
+	for i := range out {
+		if out[i].Keep != "" {
+			continue
+		}
+		if m.uninstall != nil {
+			if res := runCmd(Command{Args: m.uninstall(out[i].Version)}); res.Error != "" {
+				out[i].Error = res.Error
+				continue
+			}
+		} else if err := os.RemoveAll(out[i].Path); err != nil {
+			out[i].Error = err.Error()
+			continue
+		}
+		out[i].Removed = true
+	}
+	return out
+}
+
+// versionSummary describes a version plan for the summary table, e.g.
+// "remove 3 of 5 versions (1.2 GB)", or "" when every version is kept.
+func versionSummary(plan []VersionResult) string {
+	var n int
+	var total int64
+	for _, v := range plan {
+		if v.Keep == "" {
+			n++
+			total += v.SizeBytes
+		}
+	}
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("remove %d of %d versions (%s)", n, len(plan), human(total))
+}
+
+// cleanTargets runs each target's commands, delete policy and version policy,
+// recording the outcome of each in rep and any failure in rep.Warnings.
+func cleanTargets(targets []Target, rep *Report) {
+	for _, t := range targets {
+		if len(t.Cmds) > 0 {
+			results := make([]CmdResult, 0, len(t.Cmds))
+			for _, c := range t.Cmds {
+				res := runCmd(c)
+				if res.Error != "" {
+					rep.Warnings = append(rep.Warnings, fmt.Sprintf("[%s] %s: %s", t.Name, strings.Join(c.Args, " "), res.Error))
+				}
+				results = append(results, res)
+			}
+			rep.Commands[t.Name] = results
+		}
+		if plan := rep.Deletes[t.Name]; len(plan) > 0 {
+			rep.Deletes[t.Name] = runDeletes(t, plan)
+			for _, d := range rep.Deletes[t.Name] {
+				if d.Error != "" {
+					rep.Warnings = append(rep.Warnings, fmt.Sprintf("[%s] delete %s: %s", t.Name, d.Path, d.Error))
+				}
+			}
+		}
+		if versionSummary(rep.Versions[t.Name]) != "" {
+			rep.Versions[t.Name] = removeVersions(t, rep.Versions[t.Name])
+			for _, v := range rep.Versions[t.Name] {
+				if v.Error != "" {
+					rep.Warnings = append(rep.Warnings, fmt.Sprintf("[%s] remove %s: %s", t.Name, v.Version, v.Error))
+				}
+			}
+		}
 	}
-	return res
 }
 
 // populateCommands fills rep.Commands with command status for each target.
@@ -668,7 +1568,8 @@ func populateCommands(targets []Target, rep *Report) {
 			continue
 		}
 
-		for _, c := range t.Cmds {
+		for _, cmd := range t.Cmds {
+			c := cmd.Args
 			found := false
 			errorMsg := ""
 
@@ -696,45 +1597,219 @@ func populateCommands(targets []Target, rep *Report) {
 	}
 }
 
-// runFirstScan scans all targets and populates rep.Findings. Returns beforeTotals map.
-func runFirstScan(targets []Target, rep *Report) map[string]uint64 {
-	beforeTotals := make(map[string]uint64)
-	for _, t := range targets {
-		fmt.Printf("Scanning [%s]...", t.Name)
-		var sum int64
+// targetScan is the measured state of a single target.
+type targetScan struct {
+	findings []Finding
+	total    int64
+	warnings []string
+}
 
+// scanTargets measures every target. Docker is measured via `docker system df`; the paths of all
+// other targets are sized together by up to --jobs concurrent walkers. Results are returned in
+// target order and findings keep the order of the configured paths. progress, if not nil,
+// is called as each path finishes sizing.
+func scanTargets(targets []Target, progress func(done, total int)) []targetScan {
+	scans := make([]targetScan, len(targets))
+	var paths []string
+	var owners []int // owners[i] is the index of the target that paths[i] belongs to
+	for i, t := range targets {
 		if strings.ToLower(t.Name) == "docker" {
 			findings, total, err := dockerSystemDF()
 			if err != nil {
-				rep.Warnings = append(rep.Warnings, fmt.Sprintf("docker df error: %v", err))
+				scans[i].warnings = append(scans[i].warnings, fmt.Sprintf("docker df error: %v", err))
 			}
-			rep.Findings[t.Name] = append(rep.Findings[t.Name], findings...)
-			sum = total
-		} else {
-			var expanded []string
-			for _, p := range t.Paths {
-				matches, err := expandGlobs(p)
-				if err != nil {
-					rep.Warnings = append(rep.Warnings, fmt.Sprintf("glob error %s:%s: %v", t.Name, p, err))
-					continue
-				}
-				expanded = append(expanded, matches...)
+			scans[i].findings = findings
+			scans[i].total = total
+			continue
+		}
+		for _, p := range t.Paths {
+			matches, err := expandGlobs(p)
+			if err != nil {
+				scans[i].warnings = append(scans[i].warnings, fmt.Sprintf("glob error %s:%s: %v", t.Name, p, err))
+				continue
 			}
-			for _, p := range expanded {
-				f, err := inspectPath(p)
-				if err != nil {
-					f.Err = err.Error()
+			for _, m := range matches {
+				if fi, err := os.Stat(m); err == nil && excluder.Skip(m, fi.IsDir()) {
+					continue
 				}
-				rep.Findings[t.Name] = append(rep.Findings[t.Name], f)
-				sum += f.SizeBytes
+				paths = append(paths, m)
+				owners = append(owners, i)
 			}
 		}
-		beforeTotals[t.Name] = uint64(sum)
-		fmt.Printf(" done (%s)\n", human(sum))
+	}
+
+	sizer := newSizer()
+	sizer.Progress = progress
+	sized, errs := sizer.InspectAll(paths)
+	for n, f := range sized {
+		if errs[n] != nil {
+			f.Err = errs[n].Error()
+		}
+		i := owners[n]
+		scans[i].findings = append(scans[i].findings, f)
+		scans[i].total += f.SizeBytes
+	}
+	return scans
+}
+
+// printScanProgress keeps a single "Sizing n/m paths..." line up to date on stderr while
+// the targets are walked, so a slow first scan shows it is making progress.
+func printScanProgress(done, total int) {
+	fmt.Fprintf(os.Stderr, "\rSizing %d/%d paths...", done, total)
+	if done == total {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// runFirstScan scans all targets and populates rep.Findings. Returns beforeTotals map.
+func runFirstScan(targets []Target, rep *Report) map[string]uint64 {
+	beforeTotals := make(map[string]uint64)
+	for i, scan := range scanTargets(targets, printScanProgress) {
+		name := targets[i].Name
+		rep.Warnings = append(rep.Warnings, scan.warnings...)
+		rep.Findings[name] = append(rep.Findings[name], scan.findings...)
+		beforeTotals[name] = uint64(scan.total)
+		fmt.Printf("Scanning [%s]... done (%s)\n", name, human(scan.total))
 	}
 	return beforeTotals
 }
 
+// newHistory returns the scan history file under ~/.local/state/mac-cache-cleaner.
+func newHistory() cachecore.History { return cachecore.DefaultHistory("mac-cache-cleaner") }
+
+// historyRecord summarises a run for the scan history: sizes per target and per path
+// from the first scan and, after a cleanup, what was left of every path that shrank.
+func historyRecord(when time.Time, dryRun bool, before, after map[string][]Finding, reclaimed int64) cachecore.HistoryRecord {
+	rec := cachecore.HistoryRecord{
+		When:      when,
+		DryRun:    dryRun,
+		Reclaimed: reclaimed,
+		Groups:    map[string]map[string]int64{"target": {}},
+		Paths:     map[string]int64{},
+	}
+	for target, findings := range before {
+		var total int64
+		for _, f := range findings {
+			if f.Err != "" {
+				continue
+			}
+			total += f.SizeBytes
+			rec.Paths[f.Path] = f.SizeBytes
+		}
+		rec.Groups["target"][target] = total
+		rec.Total += total
+	}
+	if after == nil {
+		return rec
+	}
+
+	left := map[string]int64{}
+	for _, findings := range after {
+		for _, f := range findings {
+			if f.Err == "" {
+				left[f.Path] = f.SizeBytes
+			}
+		}
+	}
+	rec.Cleaned = map[string]int64{}
+	for path, n := range rec.Paths {
+		if l := left[path]; l < n {
+			rec.Cleaned[path] = l
+		}
+	}
+	return rec
+}
+
+// runHistory implements "mac-cache-cleaner history": recent runs with the bytes they
+// reclaimed, growth per target, and the paths that regrow fastest after cleaning.
+func runHistory(args []string) int {
+	set := flag.NewFlagSet("history", flag.ExitOnError)
+	limit := set.Int("limit", 10, "Number of rows to show per table (0 = all)")
+	asJSON := set.Bool("json", false, "Output the history summary as JSON")
+	_ = set.Parse(args)
+
+	h := newHistory()
+	recs, err := h.Load()
+	if err != nil {
+		fmt.Println("history error:", err)
+		return 1
+	}
+	sum := cachecore.Summarize(recs, []string{"target"})
+
+	if *asJSON {
+		b, err := json.MarshalIndent(sum, "", "  ")
+		if err != nil {
+			fmt.Println("json error:", err)
+			return 1
+		}
+		fmt.Println(string(b))
+		return 0
+	}
+	if len(recs) == 0 {
+		fmt.Printf("No history recorded yet in %s.\n", h.Path)
+		return 0
+	}
+	shown := func(i int) bool { return *limit == 0 || i < *limit }
+
+	fmt.Printf("Runs (%d recorded, latest first):\n\n", len(recs))
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("When", "Mode", "Used", "Reclaimed")
+	var reclaimed int64
+	for i := range recs {
+		rec := recs[len(recs)-1-i]
+		reclaimed += rec.Reclaimed
+		if !shown(i) {
+			continue
+		}
+		mode := "clean"
+		if rec.DryRun {
+			mode = "scan"
+		}
+		if err := table.Append(rec.When.Format(time.RFC3339), mode, human(rec.Total), human(rec.Reclaimed)); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to append table row: %v\n", err)
+		}
+	}
+	table.Footer("ALL RUNS", "", "", human(reclaimed))
+	if err := table.Render(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+	}
+
+	if rows := sum.Growth["target"]; len(rows) > 0 {
+		fmt.Printf("\nGrowth per target:\n\n")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header("Target", "Since", "First", "Latest", "Change", "Per Day")
+		for i, g := range rows {
+			if !shown(i) {
+				break
+			}
+			if err := table.Append(g.Key, g.Since.Format("2006-01-02"), human(g.First), human(g.Last), cachecore.SignedHuman(g.Last-g.First), cachecore.SignedHuman(int64(g.PerDay))); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to append table row: %v\n", err)
+			}
+		}
+		if err := table.Render(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+		}
+	}
+
+	if len(sum.Regrowth) > 0 {
+		fmt.Printf("\nFastest regrowing caches:\n\n")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header("Path", "Cleaned", "Size Now", "Per Day")
+		for i, g := range sum.Regrowth {
+			if !shown(i) {
+				break
+			}
+			if err := table.Append(g.Path, g.CleanedAt.Format("2006-01-02"), human(g.Size), cachecore.SignedHuman(int64(g.PerDay))); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to append table row: %v\n", err)
+			}
+		}
+		if err := table.Render(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering table: %v\n", err)
+		}
+	}
+	return 0
+}
+
 // selectTargets filters cfg.Targets by enabled status and targetsFlag (e.g. "all" or "docker,npm").
 func selectTargets(cfg *Config, targetsFlag string) []Target {
 	sel := map[string]bool{}
@@ -878,6 +1953,10 @@ func run() int {
 		return 0
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		return runHistory(os.Args[2:])
+	}
+
 	flag.Parse()
 	// Detect if no command-line args were provided (program name only)
 	noArgs := len(os.Args) == 1
@@ -924,16 +2003,40 @@ func run() int {
 		return 0
 	}
 
-	rep := Report{OS: runtime.GOOS, Arch: runtime.GOARCH, DryRun: !*flagClean, When: time.Now(), Totals: map[string]uint64{}, Findings: map[string][]Finding{}, Commands: map[string][]CmdResult{}, Warnings: []string{}}
-	if h, _ := os.Hostname(); h != "" {
-		rep.Hostname = h
+	if cfg.Options.CommandTimeout != "" {
+		d, err := cachecore.ParseAge(cfg.Options.CommandTimeout)
+		if err != nil || d == 0 {
+			fmt.Printf("config error: invalid commandTimeout %q\n", cfg.Options.CommandTimeout)
+			return 1
+		}
+		defaultCmdTimeout = d
+	}
+
+	if !*flagNoIndex {
+		sizeIndex = cachecore.LoadIndex(cachecore.DefaultIndexPath("mac-cache-cleaner"))
+		defer func() {
+			if err := sizeIndex.Save(); err != nil {
+				fmt.Fprintln(os.Stderr, "size index error:", err)
+			}
+		}()
+	}
+
+	excluder, err = cachecore.NewExcluder(cachecore.DefaultIgnorePath())
+	if err != nil {
+		fmt.Println("ignore file error:", err)
+		return 1
 	}
+	excluder.Add("config", cfg.Options.Exclude...)
+	excluder.Add("--exclude", *flagExclude...)
+
+	rep := Report{Envelope: cachecore.NewEnvelope(!*flagClean), Totals: map[string]uint64{}, Findings: map[string][]Finding{}, Commands: map[string][]CmdResult{}, Warnings: []string{}}
+	rep.SizeMode = newSizer().Mode()
 
 	// inject docker prune if configured or flagged
 	if cfg.Options.DockerPruneByDefault || *flagDockerPrune {
 		for i := range cfg.Targets {
 			if cfg.Targets[i].Name == "docker" {
-				cfg.Targets[i].Cmds = append(cfg.Targets[i].Cmds, []string{"docker", "builder", "prune", "-af"}, []string{"docker", "system", "prune", "-af", "--volumes"})
+				cfg.Targets[i].Cmds = append(cfg.Targets[i].Cmds, Command{Args: []string{"docker", "builder", "prune", "-af"}}, Command{Args: []string{"docker", "system", "prune", "-af", "--volumes"}})
 			}
 		}
 	}
@@ -945,11 +2048,50 @@ func run() int {
 	}
 
 	beforeTotals := runFirstScan(targets, &rep)
+	rep.Excluded = excluder.Excluded()
+	var deleteWarnings []string
+	rep.Deletes, deleteWarnings = planDeletes(targets, rep.When)
+	rep.Warnings = append(rep.Warnings, deleteWarnings...)
+	var versionWarnings []string
+	rep.Versions, versionWarnings = planVersions(targets, sourceRoots(cfg))
+	rep.Warnings = append(rep.Warnings, versionWarnings...)
+
+	// Record every run, including --json and dry runs. The cleanup below replaces
+	// rep.Findings with the re-scan, so keep the first scan for the history.
+	before := make(map[string][]Finding, len(rep.Findings))
+	for k, v := range rep.Findings {
+		before[k] = v
+	}
+	var after map[string][]Finding
+	var reclaimed int64
+	defer func() {
+		if !*flagNoHistory {
+			if err := newHistory().Append(historyRecord(rep.When, rep.DryRun, before, after, reclaimed)); err != nil {
+				fmt.Fprintln(os.Stderr, "history error:", err)
+			}
+		}
+	}()
 
 	// output initial results
 	if *flagJSON {
 		// For JSON mode, store the before totals in the report
 		rep.Totals = beforeTotals
+		if *flagClean {
+			// Keep stdout for the report: command output goes to stderr
+			cmdOutput = os.Stderr
+			cleanTargets(targets, &rep)
+			after = map[string][]Finding{}
+			rep.Freed = map[string]int64{}
+			for i, scan := range scanTargets(targets, nil) {
+				name := targets[i].Name
+				after[name] = scan.findings
+				rep.Freed[name] = int64(beforeTotals[name]) - scan.total
+				if rep.Freed[name] > 0 {
+					reclaimed += rep.Freed[name]
+				}
+			}
+			rep.Findings = after
+		}
 		b, _ := json.MarshalIndent(rep, "", "  ")
 		fmt.Println(string(b))
 		return 0
@@ -957,7 +2099,11 @@ func run() int {
 
 	fmt.Printf("Config: %s\n", *flagConfig)
 	fmt.Printf("Scan: %s\n", rep.When.Format(time.RFC3339))
-	fmt.Printf("Dry-run: %v\n\n", rep.DryRun)
+	fmt.Printf("Dry-run: %v\n", rep.DryRun)
+	if summary := cachecore.ExcludedSummary(rep.Excluded); summary != "" {
+		fmt.Println(summary)
+	}
+	fmt.Println()
 
 	populateCommands(targets, &rep)
 
@@ -1019,6 +2165,19 @@ func run() int {
 				}
 			}
 
+			if plan, ok := rep.Deletes[e.k]; ok {
+				if cmds != "" {
+					cmds += " && "
+				}
+				cmds += deleteSummary(target.Delete, plan)
+			}
+			if summary := versionSummary(rep.Versions[e.k]); summary != "" {
+				if cmds != "" {
+					cmds += " && "
+				}
+				cmds += summary
+			}
+
 			// If there are missing tools, add a message about them
 			if len(missingTools) > 0 {
 				if cmds != "" {
@@ -1072,16 +2231,31 @@ func run() int {
 					fmt.Printf("    - %s [%s]%s\n", strings.Join(c.Cmd, " "), found, err)
 				}
 			}
+			if plan := rep.Deletes[e.k]; len(plan) > 0 {
+				fmt.Println("  Delete:")
+				for _, d := range plan {
+					fmt.Printf("    - %s (%s, modified %s ago)\n", d.Path, human(d.SizeBytes), cachecore.HumanAge(rep.When.Sub(d.ModMax)))
+				}
+			}
+			if plan := rep.Versions[e.k]; len(plan) > 0 {
+				fmt.Println("  Versions:")
+				for _, v := range plan {
+					action := "remove"
+					if v.Keep != "" {
+						action = "keep: " + v.Keep
+					}
+					fmt.Printf("    - %s (%s) %s\n", v.Version, human(v.SizeBytes), action)
+				}
+			}
 			fmt.Println()
 		}
 	}
 
 	// Now run commands if --clean is specified
 	if *flagClean {
-		for _, t := range targets {
-			for _, c := range t.Cmds {
-				runCmd(c)
-			}
+		cleanTargets(targets, &rep)
+		if reported := reportedReclaimed(rep.Commands); reported > 0 {
+			fmt.Printf("\nCommands reported %s reclaimed\n", human(reported))
 		}
 
 		// SECOND SCAN - after cleanup
@@ -1091,44 +2265,19 @@ func run() int {
 		fmt.Println("Re-scanning after cleanup...")
 		fmt.Println()
 
-		for _, t := range targets {
-			fmt.Printf("Scanning [%s]...", t.Name)
-			var sum int64
-			// Clear old findings for this target
-			afterFindings := []Finding{}
-
-			if strings.ToLower(t.Name) == "docker" {
-				findings, total, _ := dockerSystemDF()
-				afterFindings = append(afterFindings, findings...)
-				sum = total
-			} else {
-				var expanded []string
-				for _, p := range t.Paths {
-					matches, err := expandGlobs(p)
-					if err != nil {
-						continue
-					}
-					expanded = append(expanded, matches...)
-				}
-				for _, p := range expanded {
-					f, err := inspectPath(p)
-					if err != nil {
-						f.Err = err.Error()
-					}
-					afterFindings = append(afterFindings, f)
-					sum += f.SizeBytes
-				}
-			}
+		for i, scan := range scanTargets(targets, printScanProgress) {
+			t := targets[i]
+			sum := scan.total
 			afterTotals[t.Name] = uint64(sum)
 			freedSpace[t.Name] = int64(beforeTotals[t.Name]) - int64(afterTotals[t.Name])
-			fmt.Printf(" done (%s", human(sum))
+			fmt.Printf("Scanning [%s]... done (%s", t.Name, human(sum))
 			if freedSpace[t.Name] > 0 {
 				fmt.Printf(", freed %s", human(freedSpace[t.Name]))
 			}
 			fmt.Println(")")
 
 			// Store findings for later display
-			rep.Findings[t.Name] = afterFindings
+			rep.Findings[t.Name] = scan.findings
 		}
 
 		// Show after scan results with freed space
@@ -1192,6 +2341,8 @@ func run() int {
 				totalFreed += fs
 			}
 		}
+		reclaimed = totalFreed
+		after = rep.Findings
 		if totalFreed > 0 {
 			fmt.Printf("Total space freed: %s\n", human(totalFreed))
 		}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"cachecore"
 )
 
 func TestCheckVersionFlag(t *testing.T) {
@@ -135,7 +140,7 @@ func TestDefaultConfigPathEmptyHome(t *testing.T) {
 }
 
 func TestRunCmdMissingBinary(t *testing.T) {
-	res := runCmd([]string{"this-binary-should-not-exist-12345"})
+	res := runCmd(Command{Args: []string{"this-binary-should-not-exist-12345"}})
 	if res.Found {
 		t.Fatalf("expected Found=false for missing binary")
 	}
@@ -300,6 +305,8 @@ func TestRunVersion(t *testing.T) {
 
 func TestRunJSON(t *testing.T) {
 	tmpDir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	defer func() { sizeIndex = nil }()
 	cfgPath := filepath.Join(tmpDir, "config.yaml")
 	// Minimal config with one fast target to avoid docker scan
 	if err := os.MkdirAll(filepath.Dir(cfgPath), 0o755); err != nil {
@@ -333,6 +340,10 @@ func TestRunJSON(t *testing.T) {
 	if !bytes.Contains(out, []byte(`"dry_run"`)) {
 		t.Fatalf("expected JSON output, got: %s", out)
 	}
+	recs, err := newHistory().Load()
+	if err != nil || len(recs) != 1 || !recs[0].DryRun {
+		t.Fatalf("expected the run to be recorded in the history, got %+v, %v", recs, err)
+	}
 }
 
 func TestLoadConfigInvalidYAML(t *testing.T) {
@@ -502,7 +513,7 @@ func TestWrapLine(t *testing.T) {
 }
 
 func TestRunCmdEmpty(t *testing.T) {
-	res := runCmd([]string{})
+	res := runCmd(Command{Args: []string{}})
 	if res.Error != "empty command" {
 		t.Fatalf("runCmd([]) error = %q, want empty command", res.Error)
 	}
@@ -510,7 +521,7 @@ func TestRunCmdEmpty(t *testing.T) {
 
 func TestRunCmdFound(t *testing.T) {
 	// Run a simple command that exists (echo or true)
-	res := runCmd([]string{"true"})
+	res := runCmd(Command{Args: []string{"true"}})
 	if !res.Found {
 		t.Fatalf("runCmd(true) Found = false")
 	}
@@ -518,7 +529,7 @@ func TestRunCmdFound(t *testing.T) {
 		t.Fatalf("runCmd(true) Error = %q", res.Error)
 	}
 	// Command with args
-	res2 := runCmd([]string{"sh", "-c", "exit 0"})
+	res2 := runCmd(Command{Args: []string{"sh", "-c", "exit 0"}})
 	if !res2.Found {
 		t.Fatalf("runCmd(sh -c) Found = false")
 	}
@@ -526,7 +537,7 @@ func TestRunCmdFound(t *testing.T) {
 
 func TestRunCmdFails(t *testing.T) {
 	// Run a command that returns non-zero (false)
-	res := runCmd([]string{"false"})
+	res := runCmd(Command{Args: []string{"false"}})
 	if !res.Found {
 		t.Fatalf("runCmd(false) Found = false - false should be in PATH")
 	}
@@ -535,6 +546,97 @@ func TestRunCmdFails(t *testing.T) {
 	}
 }
 
+func TestRunCmdBatchDoesNotWaitForInput(t *testing.T) {
+	res := runCmd(Command{Args: []string{"sh", "-c", "read answer"}, Timeout: "10s"})
+	if res.Error == "" || strings.Contains(res.Error, "timed out") {
+		t.Fatalf("expected a prompt in batch mode to read end of file and fail, got %q", res.Error)
+	}
+}
+
+func TestRunCmdAnswersPrompts(t *testing.T) {
+	script := `printf "Continue? [y/N] "; read a; [ "$a" = y ] || exit 2; printf "Really? (yes/no) "; read b; [ "$b" = yes ]`
+	prompts := []Prompt{{Expect: "[y/N]", Respond: "y"}, {Expect: "(yes/no)", Respond: "yes"}}
+	if res := runCmd(Command{Args: []string{"sh", "-c", script}, Prompts: prompts, Timeout: "10s"}); res.Error != "" {
+		t.Fatalf("expected both prompts to be answered, got %q", res.Error)
+	}
+
+	// An unexpected second question reads end of file instead of hanging
+	res := runCmd(Command{Args: []string{"sh", "-c", script}, Prompts: prompts[:1], Timeout: "10s"})
+	if res.Error == "" || strings.Contains(res.Error, "timed out") {
+		t.Fatalf("expected the unanswered prompt to fail fast, got %q", res.Error)
+	}
+}
+
+func TestRunCmdTimeout(t *testing.T) {
+	start := time.Now()
+	res := runCmd(Command{Args: []string{"sleep", "5"}, Timeout: "100ms"})
+	if !strings.Contains(res.Error, "timed out after 100ms") {
+		t.Fatalf("expected a timeout error, got %q", res.Error)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Fatalf("command was not killed at its timeout")
+	}
+	if res := runCmd(Command{Args: []string{"true"}, Timeout: "soon"}); !strings.Contains(res.Error, "invalid timeout") {
+		t.Fatalf("expected an invalid timeout error, got %q", res.Error)
+	}
+}
+
+func TestRunCmdTTY(t *testing.T) {
+	orig := stdinIsTerminal
+	defer func() { stdinIsTerminal = orig }()
+	stdinIsTerminal = func() bool { return false }
+	if res := runCmd(Command{Args: []string{"true"}, Interaction: InteractionTTY}); !strings.HasPrefix(res.Error, "skipped") {
+		t.Fatalf("expected a tty command to be skipped without a terminal, got %q", res.Error)
+	}
+	if res := runCmd(Command{Args: []string{"true"}, Interaction: "maybe"}); !strings.Contains(res.Error, "unknown interaction") {
+		t.Fatalf("expected an unknown interaction error, got %q", res.Error)
+	}
+}
+
+func TestCommandYAML(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	yml := `version: 1
+targets:
+  - name: t
+    enabled: true
+    cmds:
+      - [brew, cleanup, -s]
+      - run: [poetry, cache, clear, --all, pypi]
+        prompts:
+          - {expect: "(yes/no)", respond: "yes"}
+        timeout: 5m
+`
+	if err := os.WriteFile(cfgPath, []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	want := []Command{
+		{Args: []string{"brew", "cleanup", "-s"}},
+		{Args: []string{"poetry", "cache", "clear", "--all", "pypi"}, Prompts: []Prompt{{Expect: "(yes/no)", Respond: "yes"}}, Timeout: "5m"},
+	}
+	if !reflect.DeepEqual(cfg.Targets[0].Cmds, want) {
+		t.Fatalf("got %+v, want %+v", cfg.Targets[0].Cmds, want)
+	}
+	if cfg.Targets[0].Cmds[1].mode() != InteractionPrompts || cfg.Targets[0].Cmds[0].mode() != InteractionBatch {
+		t.Fatal("expected prompts to imply prompts mode and batch to be the default")
+	}
+
+	// Plain commands are written back as argument lists
+	if err := cachecore.WriteConfig(cfgPath, true, *cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "- - brew") || !strings.Contains(string(data), "run:") {
+		t.Fatalf("unexpected config written:\n%s", data)
+	}
+}
+
 func TestCheckToolPATH(t *testing.T) {
 	// Tool in PATH (we know 'true' or 'false' exists)
 	tool := Tool{Name: "true"}
@@ -633,12 +735,12 @@ func TestRunFirstScan(t *testing.T) {
 func TestPopulateCommands(t *testing.T) {
 	rep := &Report{Commands: map[string][]CmdResult{}, Warnings: []string{}}
 	targets := []Target{
-		{Name: "t1", Cmds: [][]string{{"true"}}, Tools: []Tool{}},
-		{Name: "t2", Cmds: [][]string{{"nonexistent-cmd-xyz"}}, Tools: []Tool{}},
-		{Name: "t3", Cmds: [][]string{{"true"}}, Tools: []Tool{{Name: "true", InstallNotes: "test note"}}},
-		{Name: "t4", Cmds: [][]string{{"missing-tool"}}, Tools: []Tool{{Name: "missing-tool", InstallCmd: "brew install x"}}},
-		{Name: "t5", Cmds: [][]string{}, Tools: []Tool{}},
-		{Name: "t6", Cmds: [][]string{{}}, Tools: []Tool{}},
+		{Name: "t1", Cmds: []Command{{Args: []string{"true"}}}, Tools: []Tool{}},
+		{Name: "t2", Cmds: []Command{{Args: []string{"nonexistent-cmd-xyz"}}}, Tools: []Tool{}},
+		{Name: "t3", Cmds: []Command{{Args: []string{"true"}}}, Tools: []Tool{{Name: "true", InstallNotes: "test note"}}},
+		{Name: "t4", Cmds: []Command{{Args: []string{"missing-tool"}}}, Tools: []Tool{{Name: "missing-tool", InstallCmd: "brew install x"}}},
+		{Name: "t5", Cmds: []Command{}, Tools: []Tool{}},
+		{Name: "t6", Cmds: []Command{{}}, Tools: []Tool{}},
 	}
 	populateCommands(targets, rep)
 	if len(rep.Commands["t1"]) != 1 {
@@ -824,3 +926,509 @@ func TestConfigIO_WriteAndLoad(t *testing.T) {
 		t.Fatalf("nested config not created: %v", err)
 	}
 }
+
+func TestTargetForOS(t *testing.T) {
+	off := false
+	tgt := Target{
+		Name:    "docker",
+		Enabled: true,
+		Notes:   "Docker",
+		Paths:   []string{"~/Library/Containers/com.docker.docker/Data"},
+		Cmds:    []Command{{Args: []string{"docker", "system", "prune", "-af"}}},
+		OS: map[string]TargetVariant{
+			"linux":   {Paths: []string{"~/.local/share/docker"}},
+			"freebsd": {Enabled: &off, Cmds: []Command{}},
+		},
+	}
+
+	if got := tgt.forOS("darwin"); !reflect.DeepEqual(got, tgt) {
+		t.Errorf("darwin: got %+v, want the target unchanged", got)
+	}
+	linux := tgt.forOS("linux")
+	if !reflect.DeepEqual(linux.Paths, []string{"~/.local/share/docker"}) {
+		t.Errorf("linux paths = %v", linux.Paths)
+	}
+	if len(linux.Cmds) != 1 || !linux.Enabled || linux.Notes != "Docker" {
+		t.Errorf("linux should keep the target's cmds, enabled and notes, got %+v", linux)
+	}
+	bsd := tgt.forOS("freebsd")
+	if bsd.Enabled || len(bsd.Cmds) != 0 || len(bsd.Paths) != 1 {
+		t.Errorf("freebsd: got %+v, want disabled with no cmds and the target's paths", bsd)
+	}
+}
+
+func TestLoadConfigAppliesOSVariant(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	yml := "version: 1\ntargets:\n  - name: t\n    enabled: true\n    paths: [/default]\n    os:\n      " + runtime.GOOS + ":\n        paths: [/host]\n"
+	if err := os.WriteFile(cfgPath, []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := cfg.Targets[0].Paths; !reflect.DeepEqual(got, []string{"/host"}) {
+		t.Errorf("paths = %v, want the %s variant's", got, runtime.GOOS)
+	}
+}
+
+func TestStarterConfigLinux(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "/data")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	cfg := starterConfig("linux")
+	byName := map[string]Target{}
+	for _, tgt := range cfg.Targets {
+		if _, dup := byName[tgt.Name]; dup {
+			t.Errorf("duplicate target %q", tgt.Name)
+		}
+		byName[tgt.Name] = tgt
+		for _, p := range tgt.Paths {
+			if strings.Contains(p, "Library") {
+				t.Errorf("%s: macOS path %s in the linux catalogue", tgt.Name, p)
+			}
+		}
+		for _, tool := range tgt.Tools {
+			if strings.HasPrefix(tool.InstallCmd, "brew ") {
+				t.Errorf("%s: brew install command %q in the linux catalogue", tgt.Name, tool.InstallCmd)
+			}
+		}
+	}
+	for _, name := range []string{"brew", "xcode", "macos"} {
+		if _, ok := byName[name]; ok {
+			t.Errorf("linux catalogue should not include %s", name)
+		}
+	}
+	if got := byName["docker"].Paths[0]; got != "$XDG_DATA_HOME/docker" {
+		t.Errorf("docker path = %s, want $XDG_DATA_HOME honoured at init", got)
+	}
+	if got := byName["go"].Paths[0]; got != "~/.cache/go-build" {
+		t.Errorf("go path = %s, want ~/.cache when XDG_CACHE_HOME is unset", got)
+	}
+	if _, ok := byName["podman"]; !ok {
+		t.Error("linux catalogue should include podman")
+	}
+
+	if mac := starterConfig("darwin"); len(mac.Targets) == 0 || mac.Targets[1].Name != "brew" {
+		t.Errorf("darwin catalogue should be the macOS one")
+	}
+}
+
+func TestScanTargetsKeepsPathOrder(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i, size := range []int{300, 1, 20} {
+		p := filepath.Join(dir, fmt.Sprintf("p%d", i))
+		if err := os.WriteFile(p, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	targets := []Target{
+		{Name: "first", Paths: paths[:2]},
+		{Name: "second", Paths: []string{paths[2], filepath.Join(dir, "missing-*")}},
+	}
+	scans := scanTargets(targets, nil)
+	if len(scans) != 2 {
+		t.Fatalf("expected 2 scans, got %d", len(scans))
+	}
+	if scans[0].total != 301 || scans[1].total != 20 {
+		t.Fatalf("unexpected totals: %d, %d", scans[0].total, scans[1].total)
+	}
+	if scans[0].findings[0].Path != paths[0] || scans[0].findings[1].Path != paths[1] {
+		t.Fatalf("findings out of order: %+v", scans[0].findings)
+	}
+	if len(scans[1].findings) != 1 || scans[1].findings[0].Path != paths[2] {
+		t.Fatalf("unexpected findings for second target: %+v", scans[1].findings)
+	}
+}
+
+func TestScanTargetsExcludes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Caches/app/a", "Caches/com.apple.x/b", "Caches/app/Index/c"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 10), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := excluder
+	defer func() { excluder = old }()
+	excluder, _ = cachecore.NewExcluder("")
+	excluder.Add("--exclude", "com.apple.*", "Index/")
+
+	scans := scanTargets([]Target{{Name: "caches", Paths: []string{filepath.Join(dir, "Caches", "*")}}}, nil)
+	if len(scans[0].findings) != 1 || scans[0].total != 10 || scans[0].findings[0].Excluded != 1 {
+		t.Fatalf("expected only app/a to be sized, got %+v", scans[0].findings)
+	}
+	if got := cachecore.ExcludedSummary(excluder.Excluded()); !strings.HasPrefix(got, "2 paths excluded:") {
+		t.Fatalf("unexpected summary %q", got)
+	}
+}
+
+func TestDockerFindingSizes(t *testing.T) {
+	f := dockerFinding("Images", 1024, 3)
+	if f.Path != "docker:images" || f.Items != 3 {
+		t.Fatalf("unexpected finding: %+v", f)
+	}
+	if f.SizeBytes != 1024 || f.ApparentBytes != 1024 || f.DiskBytes != 1024 {
+		t.Fatalf("expected docker size in every size field, got %+v", f)
+	}
+}
+
+func TestHistoryRecord(t *testing.T) {
+	when := time.Now()
+	before := map[string][]Finding{
+		"npm":  {{Path: "/npm/a", SizeBytes: 100}, {Path: "/npm/b", SizeBytes: 50}},
+		"brew": {{Path: "/brew", SizeBytes: 30}, {Path: "/brew/missing", Err: "not found"}},
+		"pip":  nil,
+	}
+
+	rec := historyRecord(when, true, before, nil, 0)
+	if rec.Total != 180 || rec.Groups["target"]["npm"] != 150 || rec.Groups["target"]["brew"] != 30 || len(rec.Paths) != 3 || rec.Cleaned != nil {
+		t.Fatalf("unexpected dry-run record: %+v", rec)
+	}
+	if _, ok := rec.Groups["target"]["pip"]; !ok {
+		t.Fatal("expected empty targets to be recorded with 0 bytes")
+	}
+
+	after := map[string][]Finding{
+		"npm":  {{Path: "/npm/a", SizeBytes: 10}, {Path: "/npm/b", SizeBytes: 50}},
+		"brew": {},
+	}
+	rec = historyRecord(when, false, before, after, 120)
+	if rec.Reclaimed != 120 || len(rec.Cleaned) != 2 || rec.Cleaned["/npm/a"] != 10 || rec.Cleaned["/brew"] != 0 {
+		t.Fatalf("unexpected cleaned paths: %v", rec.Cleaned)
+	}
+}
+
+func TestPlanAndRunDeletes(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour)
+	for name, mtime := range map[string]time.Time{"old-idx": old, "new-idx": now, "old-keep": old, "old.log": old} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		f := filepath.Join(p, "data")
+		if err := os.WriteFile(f, make([]byte, 10), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range []string{f, p} {
+			if err := os.Chtimes(q, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	origRunning := processRunning
+	defer func() { processRunning = origRunning }()
+	running := false
+	processRunning = func(name string) (bool, error) { return running, nil }
+
+	policy := &DeletePolicy{Enabled: true, OlderThan: "30d", Include: []string{"*-idx", "*-keep"}, Exclude: []string{"*keep"}, UnlessRunning: []string{"idea"}}
+	targets := []Target{
+		{Name: "jetbrains", Paths: []string{dir}, Delete: policy},
+		{Name: "off", Paths: []string{dir}, Delete: &DeletePolicy{}},
+		{Name: "none", Paths: []string{dir}},
+	}
+
+	plans, warnings := planDeletes(targets, now)
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if len(plans) != 1 || len(plans["jetbrains"]) != 1 || plans["jetbrains"][0].Path != filepath.Join(dir, "old-idx") {
+		t.Fatalf("expected only old-idx to be planned, got %+v", plans)
+	}
+	if got := deleteSummary(policy, plans["jetbrains"]); got != "delete 1 entry older than 30d (10 B)" {
+		t.Errorf("deleteSummary = %q", got)
+	}
+
+	running = true
+	if plans, warnings := planDeletes(targets, now); len(plans) != 0 || len(warnings) != 1 || !strings.Contains(warnings[0], "idea is running") {
+		t.Fatalf("expected a running IDE to block the plan, got %v %v", plans, warnings)
+	}
+	res := runDeletes(targets[0], plans["jetbrains"])
+	if res[0].Deleted || !strings.Contains(res[0].Error, "idea is running") {
+		t.Fatalf("expected the delete to be skipped while idea runs, got %+v", res[0])
+	}
+
+	running = false
+	res = runDeletes(targets[0], plans["jetbrains"])
+	if !res[0].Deleted || res[0].Error != "" {
+		t.Fatalf("expected old-idx to be deleted, got %+v", res[0])
+	}
+	for name, want := range map[string]bool{"old-idx": false, "new-idx": true, "old-keep": true, "old.log": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+}
+
+func TestPlanDeletesBypassesIndex(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "cache-idx")
+	if err := os.MkdirAll(entry, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f := filepath.Join(entry, "data")
+	if err := os.WriteFile(f, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	for _, q := range []string{f, entry} {
+		if err := os.Chtimes(q, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	origIndex := sizeIndex
+	defer func() { sizeIndex = origIndex }()
+	sizeIndex = cachecore.LoadIndex(filepath.Join(t.TempDir(), "index.json"))
+	if _, errs := newSizer().InspectAll([]string{entry}); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+
+	// Rewrite the file in place; the entry's mtime stays in the past
+	if err := os.WriteFile(f, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(entry, old, old); err != nil {
+		t.Fatal(err)
+	}
+	targets := []Target{{Name: "jetbrains", Paths: []string{dir}, Delete: &DeletePolicy{Enabled: true, OlderThan: "30d"}}}
+	if plans, warnings := planDeletes(targets, time.Now()); len(plans) != 0 || len(warnings) != 0 {
+		t.Fatalf("expected the rewritten entry to be kept, got %v %v", plans, warnings)
+	}
+}
+
+func TestDeletePolicyGuards(t *testing.T) {
+	if err := (&DeletePolicy{OlderThan: "soon"}).validate(); err == nil {
+		t.Error("expected an invalid age to be rejected")
+	}
+	if err := (&DeletePolicy{Include: []string{"["}}).validate(); err == nil {
+		t.Error("expected an invalid glob to be rejected")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, dir := range []string{"/", home, filepath.Dir(home), "relative/dir"} {
+		if err := checkDeleteRoot(dir); err == nil {
+			t.Errorf("expected deleting under %s to be refused", dir)
+		}
+	}
+	if err := checkDeleteRoot(filepath.Join(home, ".cache")); err != nil {
+		t.Errorf("unexpected refusal: %v", err)
+	}
+
+	plans, warnings := planDeletes([]Target{{Name: "home", Paths: []string{"~"}, Delete: &DeletePolicy{Enabled: true}}}, time.Now())
+	if len(plans) != 0 || len(warnings) != 1 || !strings.Contains(warnings[0], "refusing") {
+		t.Fatalf("expected a policy on ~ to be refused, got %v %v", plans, warnings)
+	}
+}
+
+func TestParseVersionFile(t *testing.T) {
+	cases := []struct {
+		name, data string
+		want       []string
+	}{
+		{".python-version", "3.11\n# comment\nsystem\n3.12.1\n", []string{"3.11", "3.12.1"}},
+		{".nvmrc", "v18.17.0\n", []string{"v18.17.0"}},
+		{"rust-toolchain.toml", "[toolchain]\nchannel = \"1.75.0\"\ncomponents = [\"clippy\"]\n", []string{"1.75.0"}},
+		{"rust-toolchain", "nightly-2024-01-01\n", []string{"nightly-2024-01-01"}},
+		{"settings.toml", "version = \"12\"\ndefault_toolchain = \"stable-aarch64-apple-darwin\"\n", []string{"stable-aarch64-apple-darwin"}},
+		{"settings.toml", "default_toolchain = \"stable\"\n\n[overrides]\n\"/src/old\" = \"1.70.0-aarch64-apple-darwin\"\n\n[other]\nkey = \"x\"\n", []string{"stable", "1.70.0-aarch64-apple-darwin"}},
+		{"alias", "default=ruby-3.2.2\n", []string{"ruby-3.2.2"}},
+		{"gradle-wrapper.properties", "distributionBase=GRADLE_USER_HOME\ndistributionUrl=https\\://services.gradle.org/distributions/gradle-8.5-bin.zip\n", []string{"gradle-8.5-bin"}},
+	}
+	for _, c := range cases {
+		if got := parseVersionFile(c.name, []byte(c.data)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestVersionMatches(t *testing.T) {
+	cases := []struct {
+		manager, version, ref string
+		want                  bool
+	}{
+		{"pyenv", "3.11.4", "3.11", true},
+		{"pyenv", "3.11.4", "3.1", false},
+		{"nvm", "v18.17.0", "18", true},
+		{"nvm", "v18.17.0", "v18.17.0", true},
+		{"nvm", "v18.17.0", "lts/*", false},
+		{"rustup", "stable-aarch64-apple-darwin", "stable", true},
+		{"rustup", "1.75.0-x86_64-unknown-linux-gnu", "1.75.0", true},
+		{"rvm", "ruby-3.2.2", "3.2", true},
+		{"gradle", "gradle-8.5-bin", "gradle-8.5", false},
+	}
+	for _, c := range cases {
+		if got := versionManagers[c.manager].versionMatches(c.version, c.ref); got != c.want {
+			t.Errorf("%s: %q matches %q = %v, want %v", c.manager, c.ref, c.version, got, c.want)
+		}
+	}
+}
+
+func TestPlanVersions(t *testing.T) {
+	root := t.TempDir()
+	versions := filepath.Join(root, "pyenv", "versions")
+	now := time.Now()
+	for i, v := range []string{"3.12.1", "3.11.4", "3.10.2", "3.9.1", "3.8.0"} {
+		p := filepath.Join(versions, v)
+		if err := os.MkdirAll(filepath.Join(p, "bin"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if v == "3.10.2" {
+			if err := os.MkdirAll(filepath.Join(p, "envs", "tools"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		mtime := now.Add(-time.Duration(i) * 24 * time.Hour)
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(versions, "3.9.1"), filepath.Join(versions, "legacy")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pyenv", "version"), []byte("3.11.4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(root, "src")
+	for dir, pin := range map[string]string{"app": "legacy\n", "node_modules/dep": "3.8.0\n"} {
+		if err := os.MkdirAll(filepath.Join(src, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, dir, ".python-version"), []byte(pin), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := Target{Name: "pyenv", Paths: []string{versions, filepath.Join(root, "pyenv", "cache")}, Versions: &VersionPolicy{Enabled: true, Manager: "pyenv", Keep: 1}}
+	plans, warnings := planVersions([]Target{target}, []string{src})
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	keep := map[string]string{}
+	for _, v := range plans["pyenv"] {
+		keep[v.Version] = v.Keep
+	}
+	want := map[string]string{
+		"3.12.1": "recently installed",
+		"3.11.4": "pinned by " + filepath.Join(root, "pyenv", "version"),
+		"3.10.2": "has virtualenvs",
+		"3.9.1":  "pinned by " + filepath.Join(src, "app", ".python-version"),
+		"3.8.0":  "",
+	}
+	if !reflect.DeepEqual(keep, want) {
+		t.Fatalf("got %v, want %v", keep, want)
+	}
+	if got := versionSummary(plans["pyenv"]); !strings.HasPrefix(got, "remove 1 of 5 versions") {
+		t.Errorf("versionSummary = %q", got)
+	}
+
+	if plans, warnings := planVersions([]Target{target}, nil); len(plans) != 0 || len(warnings) != 1 {
+		t.Fatalf("expected no plan without source roots, got %v %v", plans, warnings)
+	}
+}
+
+func TestRemoveVersionsDeletesGradleDists(t *testing.T) {
+	dists := filepath.Join(t.TempDir(), "wrapper", "dists")
+	for _, d := range []string{"gradle-8.5-bin", "gradle-7.6-all"} {
+		if err := os.MkdirAll(filepath.Join(dists, d, "abc123"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := t.TempDir()
+	props := filepath.Join(src, "app", "gradle", "wrapper")
+	if err := os.MkdirAll(props, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(props, "gradle-wrapper.properties"), []byte("distributionUrl=https\\://services.gradle.org/distributions/gradle-8.5-bin.zip\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := Target{Name: "gradle", Paths: []string{dists}, Versions: &VersionPolicy{Enabled: true, Manager: "gradle"}}
+	plans, _ := planVersions([]Target{target}, []string{src})
+	res := removeVersions(target, plans["gradle"])
+	for _, v := range res {
+		if v.Removed != (v.Version == "gradle-7.6-all") || v.Error != "" {
+			t.Errorf("unexpected outcome for %s: %+v", v.Version, v)
+		}
+		if _, err := os.Stat(v.Path); (err == nil) == v.Removed {
+			t.Errorf("%s exists = %v after removal = %v", v.Version, err == nil, v.Removed)
+		}
+	}
+}
+
+func TestRunCmdCapturesResult(t *testing.T) {
+	orig := cmdOutput
+	defer func() { cmdOutput = orig }()
+	cmdOutput = io.Discard
+
+	res := runCmd(Command{Args: []string{"sh", "-c", "echo out; echo err >&2; echo 'Total reclaimed space: 2MB'; exit 3"}})
+	if res.ExitCode == nil || *res.ExitCode != 3 || res.Error == "" {
+		t.Fatalf("expected exit code 3 and an error, got %+v", res)
+	}
+	if res.Stdout != "out\nTotal reclaimed space: 2MB\n" || res.Stderr != "err\n" {
+		t.Fatalf("unexpected output tails: %q / %q", res.Stdout, res.Stderr)
+	}
+	if res.Start == nil || res.End == nil || res.End.Before(*res.Start) || res.DurationMS < 0 {
+		t.Fatalf("unexpected timing: %+v", res)
+	}
+	if res.ReclaimedBytes != 0 {
+		t.Fatalf("only known commands should have reclaimed space parsed, got %d", res.ReclaimedBytes)
+	}
+
+	res = runCmd(Command{Args: []string{"sleep", "5"}, Timeout: "100ms"})
+	if res.ExitCode != nil {
+		t.Fatalf("a killed command should have no exit code, got %d", *res.ExitCode)
+	}
+}
+
+func TestRunCmdTTYKeepsStdoutOnCmdOutput(t *testing.T) {
+	origOut, origTerm := cmdOutput, stdinIsTerminal
+	defer func() { cmdOutput, stdinIsTerminal = origOut, origTerm }()
+	var out bytes.Buffer
+	cmdOutput = &out
+	stdinIsTerminal = func() bool { return true }
+
+	if res := runCmd(Command{Args: []string{"echo", "cleared"}, Interaction: InteractionTTY}); res.Error != "" {
+		t.Fatalf("unexpected error: %s", res.Error)
+	}
+	if out.String() != "cleared\n" {
+		t.Fatalf("expected the tty command's stdout on cmdOutput, got %q", out.String())
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	var b tailBuffer
+	_, _ = b.Write([]byte("short"))
+	if b.String() != "short" {
+		t.Fatalf("got %q", b.String())
+	}
+	_, _ = b.Write(bytes.Repeat([]byte("x"), cmdTailBytes))
+	if got := b.String(); len(got) != cmdTailBytes+3 || !strings.HasPrefix(got, "...x") {
+		t.Fatalf("expected the last %d bytes after an ellipsis, got %d bytes", cmdTailBytes, len(got))
+	}
+}
+
+func TestParseReclaimed(t *testing.T) {
+	cases := []struct {
+		cmd, out string
+		want     int64
+	}{
+		{"docker", "Deleted Images:\nuntagged: foo\n\nTotal reclaimed space: 1.5GB\n", 1536 * 1024 * 1024},
+		{"docker", "ID\tRECLAIMABLE\nabc\t12MB\nTotal:\t12MB\n", 12 * 1024 * 1024},
+		{"/usr/local/bin/podman", "Total reclaimed space: 0B\n", 0},
+		{"brew", "Removing: /Users/me/Library/Caches/Homebrew/foo... (1.2MB)\n==> This operation has freed approximately 512MB of disk space.\n", 512 * 1024 * 1024},
+		{"npm", "Total reclaimed space: 1GB\n", 0},
+	}
+	for _, c := range cases {
+		if got := parseReclaimed(c.cmd, c.out); got != c.want {
+			t.Errorf("%s: got %d, want %d", c.cmd, got, c.want)
+		}
+	}
+}